@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"net/http"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/auth"
+)
+
+// WithAuth включает аутентификацию: запросы без действительного API-ключа или JWT
+// получают 401 до обращения к хранилищу. Предварительные CORS-запросы (OPTIONS)
+// не требуют учетных данных.
+func WithAuth(a *auth.Authenticator) Option {
+	return func(hs *HttpServer) {
+		hs.auth = a
+	}
+}
+
+// authenticate пропускает к next только аутентифицированные запросы
+// и сохраняет клиента в контексте запроса (auth.FromContext).
+func (hs *HttpServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		principal, err := hs.auth.Authenticate(req)
+		if err != nil {
+			eW := newHandlerEWrapper(req, "(hs *HttpServer) authenticate()")
+			resp := &dto.Response{}
+			wrapError(resp, "Unauthorized", err)
+			eW.LogError(err, "hs.auth.Authenticate(req)")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="notes"`)
+			respond(w, req, eW, http.StatusUnauthorized, resp)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(auth.NewContext(req.Context(), principal)))
+	})
+}
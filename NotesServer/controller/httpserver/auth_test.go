@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"NotesServer/gates/storage/mp"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/auth"
+	"strings"
+	"testing"
+)
+
+// TestAuthBeforeStorage проверяет, что запрос без действительных учетных данных
+// получает 401, а хранилище арендатора даже не открывается.
+func TestAuthBeforeStorage(t *testing.T) {
+	keys, err := auth.LoadAPIKeys(writeFile(t, "keys.json", `{"api_keys":[{"name":"alice","key":"ka"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := 0
+	tenants := tenant.NewRegistry(func(string) (*Tenant, error) {
+		opened++
+		return NewTenant(mp.NewMap[*dto.Note]())
+	})
+	hs := NewHttpServer(":0", tenants,
+		WithLegacyEndpoints(true),
+		WithAuth(&auth.Authenticator{Keys: keys}),
+		WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+
+	const note = `{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`
+	requests := []struct {
+		method, path, body string
+	}{
+		{"POST", notesPath, note},
+		{"GET", notesPath, ""},
+		{"GET", notePath(1), ""},
+		{"PATCH", notePath(1), `{"note":"x"}`},
+		{"DELETE", notePath(1), ""},
+		{"DELETE", notesPath, ""},
+		{"GET", searchPath + "?q=milk", ""},
+		{"POST", "/create", note},
+		{"POST", "/get-all", ""},
+	}
+	for _, key := range []string{"", "wrong"} {
+		for _, r := range requests {
+			req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+			if key != "" {
+				req.Header.Set(auth.APIKeyHeader, key)
+			}
+			rec := httptest.NewRecorder()
+			hs.srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != 401 {
+				t.Errorf("%s %s with key %q: status %d, want 401", r.method, r.path, key, rec.Code)
+			}
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%s %s with key %q: no WWW-Authenticate", r.method, r.path, key)
+			}
+		}
+	}
+	if opened != 0 {
+		t.Fatalf("storage opened %d times for unauthenticated requests", opened)
+	}
+
+	req := httptest.NewRequest("POST", notesPath, strings.NewReader(note))
+	req.Header.Set(auth.APIKeyHeader, "ka")
+	rec := httptest.NewRecorder()
+	hs.srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != 201 || opened != 1 {
+		t.Errorf("POST /notes with key: status %d, storage opened %d times", rec.Code, opened)
+	}
+}
@@ -0,0 +1,187 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/auth"
+	"NotesServer/pkg/validate"
+)
+
+// statusClientClosedRequest - нестандартный статус (nginx) для запросов, клиент которых отключился.
+const statusClientClosedRequest = 499
+
+var (
+	// errMissingData - в запросе нет ни одного поля для изменения.
+	errMissingData = errors.New("required data is missing")
+	// errMissingID - в теле запроса старого эндпоинта нет id заметки.
+	errMissingID = errors.New("id is missing")
+	// errInvalidID - id в пути /notes/{id} не является положительным целым числом.
+	errInvalidID = errors.New("id must be a positive integer")
+	// errNoRecords - заметок, подходящих под запрос, нет.
+	errNoRecords = errors.New("no records found")
+	// errBadPatch - тело PATCH не является корректным JSON Merge Patch для заметки.
+	errBadPatch = errors.New("invalid merge patch")
+	// errIDChange - запрос пытается изменить id заметки.
+	errIDChange = errors.New("note id cannot be changed")
+	// errUnsupportedMediaType - тип содержимого запроса не поддерживается.
+	errUnsupportedMediaType = errors.New("unsupported content type")
+)
+
+// Стабильные коды ошибок в dto.Response.Code и dto.Problem.Code: клиенты выбирают
+// по ним поведение, не разбирая текст ошибки. Менять существующие коды нельзя.
+const (
+	codeBodyTooLarge         = "body_too_large"
+	codeValidationFailed     = "validation_failed"
+	codeMissingData          = "missing_data"
+	codeIDChange             = "id_change"
+	codeTypeMismatch         = "type_mismatch"
+	codeInvalidBody          = "invalid_body"
+	codeInvalidPatch         = "invalid_patch"
+	codeInvalidQuery         = "invalid_query"
+	codeInvalidID            = "invalid_id"
+	codeIndexOutOfRange      = "index_out_of_range"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeNoCredentials        = "no_credentials"
+	codeInvalidAPIKey        = "invalid_api_key"
+	codeInvalidToken         = "invalid_token"
+	codeTokenExpired         = "token_expired"
+	codeForbidden            = "forbidden"
+	codeInvalidTenant        = "invalid_tenant"
+	codeNotFound             = "not_found"
+	codeIndexExists          = "index_exists"
+	codePreconditionFailed   = "precondition_failed"
+	codeClientClosed         = "client_closed"
+	codeInternal             = "internal"
+	codeNotReady             = "not_ready"
+	codeStorageUnavailable   = "storage_unavailable"
+	codeTimeout              = "timeout"
+)
+
+// errorKind - класс ошибки: HTTP-статус ответа, стабильный код и заголовок для problem+json.
+type errorKind struct {
+	status int
+	code   string
+	title  string
+}
+
+// internalError - класс ошибок, которых нет в errorKinds.
+var internalError = errorKind{http.StatusInternalServerError, codeInternal, "Internal server error"}
+
+// errorKinds сопоставляет ошибкам хранилища и обработчиков их классы.
+// Проверяются по порядку, выигрывает первое совпадение.
+var errorKinds = []struct {
+	match func(err error) bool
+	kind  errorKind
+}{
+	{as[*http.MaxBytesError], errorKind{http.StatusRequestEntityTooLarge, codeBodyTooLarge, "Request body is too large"}},
+	{is(errIDChange), errorKind{http.StatusUnprocessableEntity, codeIDChange, "Note id cannot be changed"}},
+	{as[validate.Errors], errorKind{http.StatusUnprocessableEntity, codeValidationFailed, "Validation failed"}},
+	{is(errMissingData), errorKind{http.StatusUnprocessableEntity, codeMissingData, "Required data is missing"}},
+	{is(errMissingID), errorKind{http.StatusUnprocessableEntity, codeMissingData, "Required data is missing"}},
+	{is(storage.ErrMismatchType), errorKind{http.StatusUnprocessableEntity, codeTypeMismatch, "Mismatched type"}},
+	{is(storage.ErrNotComparable), errorKind{http.StatusUnprocessableEntity, codeTypeMismatch, "Value is not comparable"}},
+	{as[*bodyError], errorKind{http.StatusBadRequest, codeInvalidBody, "Malformed request body"}},
+	{is(errBadPatch), errorKind{http.StatusBadRequest, codeInvalidPatch, "Invalid merge patch"}},
+	{is(errBadQuery), errorKind{http.StatusBadRequest, codeInvalidQuery, "Invalid query"}},
+	{is(errInvalidID), errorKind{http.StatusBadRequest, codeInvalidID, "Invalid note id"}},
+	{is(storage.ErrIndexOutOfRange), errorKind{http.StatusBadRequest, codeIndexOutOfRange, "Index out of range"}},
+	{is(errUnsupportedMediaType), errorKind{http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Unsupported content type"}},
+	{is(auth.ErrNoCredentials), errorKind{http.StatusUnauthorized, codeNoCredentials, "Authentication required"}},
+	{is(auth.ErrInvalidAPIKey), errorKind{http.StatusUnauthorized, codeInvalidAPIKey, "Invalid API key"}},
+	{is(auth.ErrInvalidToken), errorKind{http.StatusUnauthorized, codeInvalidToken, "Invalid token"}},
+	{is(auth.ErrTokenExpired), errorKind{http.StatusUnauthorized, codeTokenExpired, "Token expired"}},
+	{is(auth.ErrForbidden), errorKind{http.StatusForbidden, codeForbidden, "Forbidden"}},
+	{is(tenant.ErrInvalidTenant), errorKind{http.StatusForbidden, codeInvalidTenant, "Invalid tenant"}},
+	{is(errPreconditionFailed), errorKind{http.StatusPreconditionFailed, codePreconditionFailed, "Precondition failed"}},
+	{is(storage.ErrNotFound), errorKind{http.StatusNotFound, codeNotFound, "Note not found"}},
+	{is(errNoRecords), errorKind{http.StatusNotFound, codeNotFound, "Note not found"}},
+	{is(storage.ErrIndexExists), errorKind{http.StatusConflict, codeIndexExists, "Index exists"}},
+	{is(errNotReady), errorKind{http.StatusServiceUnavailable, codeNotReady, "Server is not ready"}},
+	{is(errStorageUnhealthy), errorKind{http.StatusServiceUnavailable, codeStorageUnavailable, "Storage is unavailable"}},
+	{is(context.DeadlineExceeded), errorKind{http.StatusGatewayTimeout, codeTimeout, "Request timed out"}},
+	{is(context.Canceled), errorKind{statusClientClosedRequest, codeClientClosed, "Client closed request"}},
+}
+
+func is(target error) func(error) bool {
+	return func(err error) bool { return errors.Is(err, target) }
+}
+
+func as[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
+
+// classify возвращает класс ошибки хранилища или обработчика.
+func classify(err error) errorKind {
+	for _, k := range errorKinds {
+		if k.match(err) {
+			return k.kind
+		}
+	}
+	return internalError
+}
+
+// errorStatus возвращает HTTP-статус ответа для ошибки хранилища или обработчика.
+func errorStatus(err error) int {
+	return classify(err).status
+}
+
+// errorTitle возвращает заголовок класса ошибок с кодом code.
+func errorTitle(code string) string {
+	for _, k := range errorKinds {
+		if k.kind.code == code {
+			return k.kind.title
+		}
+	}
+	return internalError.title
+}
+
+// bodyError - ошибка чтения или разбора тела запроса. Текст не меняется,
+// а превышение размера тела и ошибки полей видны через errors.As.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *bodyError) Unwrap() error {
+	return e.err
+}
+
+// badBody помечает err как ошибку тела запроса; nil возвращается как есть.
+func badBody(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &bodyError{err: err}
+}
+
+// idChangeError возвращает errIDChange вместе с ошибкой поля id, чтобы клиент
+// видел, какое поле тела запроса отклонено. Код ответа - id_change.
+func idChangeError() error {
+	return fmt.Errorf("%w: %w", errIDChange, validate.Errors{
+		{Field: "id", Code: codeIDChange, Message: "must be equal to the note id in the path"},
+	})
+}
+
+// fieldErrors возвращает ошибки полей, если err - ошибка проверки тела запроса (validate.Errors).
+// Они передаются клиенту в dto.Response.Errors.
+func fieldErrors(err error) validate.Errors {
+	var errs validate.Errors
+	errors.As(err, &errs)
+	return errs
+}
+
+// wrapError заполняет ответ resp ошибкой err: текст, стабильный код и ошибки полей.
+func wrapError(resp *dto.Response, result string, err error) {
+	resp.Wrap(result, nil, err.Error())
+	resp.Code = classify(err).code
+	resp.Errors = fieldErrors(err)
+}
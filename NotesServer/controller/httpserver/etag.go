@@ -0,0 +1,112 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"NotesServer/gates/storage"
+	"NotesServer/models/dto"
+	"strconv"
+	"strings"
+)
+
+// Оптимистичная блокировка заметок.
+//
+// У каждой заметки есть версия, которая увеличивается при каждом изменении.
+// Версия отдается в заголовке ETag. Изменение и удаление с заголовком If-Match
+// выполняются, только если версия заметки не изменилась, иначе ответ 412.
+// Чтение с заголовком If-None-Match отвечает 304, если у клиента актуальная версия.
+
+// errPreconditionFailed - версия заметки не совпадает с заголовком If-Match.
+var errPreconditionFailed = errors.New("precondition failed: the note has been changed")
+
+// etag возвращает ETag версии заметки.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag выставляет заголовок ETag для заметки.
+func setETag(w http.ResponseWriter, note *dto.Note) {
+	w.Header().Set("ETag", etag(note.Version))
+}
+
+// etagMatches сообщает, совпадает ли версия с одним из тегов списка header.
+// "*" совпадает с любой версией. Слабые теги (W/"...") совпадают только при weak:
+// If-None-Match использует слабое сравнение, If-Match - сильное.
+func etagMatches(header string, version int64, weak bool) bool {
+	want := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[len("W/"):]
+		}
+		if tag == want {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified сообщает, что у клиента уже есть текущая версия заметки (If-None-Match).
+func notModified(req *http.Request, note *dto.Note) bool {
+	header := req.Header.Get("If-None-Match")
+	return header != "" && etagMatches(header, note.Version, true)
+}
+
+// checkIfMatch возвращает errPreconditionFailed, если в запросе есть If-Match
+// и он не совпадает с версией заметки current.
+func checkIfMatch(req *http.Request, current *dto.Note) error {
+	header := req.Header.Get("If-Match")
+	if header == "" || etagMatches(header, current.Version, false) {
+		return nil
+	}
+	return errPreconditionFailed
+}
+
+// modifyNote атомарно изменяет заметку id: проверяет If-Match, применяет fn к текущей
+// заметке и увеличивает версию результата. id и владелец заметки не меняются.
+func (hs *HttpServer) modifyNote(req *http.Request, id int64, fn func(current *dto.Note) (*dto.Note, error)) (*dto.Note, error) {
+	return notesOf(req.Context()).Modify(req.Context(), id, func(current *dto.Note) (*dto.Note, error) {
+		if err := checkIfMatch(req, current); err != nil {
+			return nil, err
+		}
+		updated, err := fn(current)
+		if err != nil {
+			return nil, err
+		}
+		updated.ID = current.ID
+		updated.Owner = current.Owner
+		updated.Version = current.Version + 1
+		return updated, nil
+	})
+}
+
+// removeNote удаляет заметку id с учетом If-Match. Проверенная заметка удаляется
+// через RemoveByValue, поэтому изменение между проверкой и удалением тоже дает 412.
+func (hs *HttpServer) removeNote(req *http.Request, id int64) error {
+	st := notesOf(req.Context())
+	if req.Header.Get("If-Match") == "" {
+		return st.RemoveByIndex(req.Context(), id)
+	}
+
+	current, ok, err := st.GetByIndex(req.Context(), id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if err := checkIfMatch(req, current); err != nil {
+		return err
+	}
+	err = st.RemoveByValue(req.Context(), current)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errPreconditionFailed
+	}
+	return err
+}
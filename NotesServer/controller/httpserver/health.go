@@ -0,0 +1,106 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"NotesServer/gates/storage"
+	"NotesServer/models/dto"
+	"time"
+)
+
+// Проверки состояния для оркестратора; аутентификация для них не требуется:
+//
+//	GET /healthz - процесс жив и обслуживает запросы (liveness), всегда 200
+//	GET /readyz  - сервер готов принимать запросы (readiness): 200 или 503
+//
+// Сервер не готов, пока main не вызвал SetReady (например, хранилище еще восстанавливается
+// из журнала), после начала Shutdown и пока хотя бы одно открытое хранилище арендатора,
+// реализующее storage.HealthChecker, сообщает об ошибке. В data ответа /readyz - состояние
+// каждого открытого хранилища: "ok" или текст ошибки.
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// healthTimeout ограничивает время проверки одного хранилища.
+const healthTimeout = 2 * time.Second
+
+var (
+	// errNotReady - сервер еще не готов или уже останавливается.
+	errNotReady = errors.New("server is not ready")
+	// errStorageUnhealthy - хотя бы одно хранилище арендатора недоступно.
+	errStorageUnhealthy = errors.New("storage is unavailable")
+)
+
+// SetReady отмечает, что сервер готов (или больше не готов) принимать запросы.
+func (hs *HttpServer) SetReady(ready bool) {
+	hs.ready.Store(ready)
+}
+
+// Health проверяет хранилище арендатора, если оно или вложенное в него хранилище
+// реализует storage.HealthChecker; иначе хранилище считается готовым.
+func (t *Tenant) Health(ctx context.Context) error {
+	hc, ok := storeAs[storage.HealthChecker](t.Store)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	return hc.Health(ctx)
+}
+
+func (hs *HttpServer) healthzHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		methodNotAllowed(w, "GET, HEAD")
+		return
+	}
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) healthzHandler()")
+	resp := &dto.Response{}
+	resp.Wrap("ok", nil, "")
+	respond(w, req, eW, http.StatusOK, resp)
+}
+
+func (hs *HttpServer) readyzHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		methodNotAllowed(w, "GET, HEAD")
+		return
+	}
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) readyzHandler()")
+	resp := &dto.Response{}
+
+	if !hs.ready.Load() {
+		wrapError(resp, "Not ready", errNotReady)
+		respond(w, req, eW, http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	checks := make(map[string]string)
+	var errs []error
+	hs.tenants.Each(func(name string, t *Tenant) {
+		if err := t.Health(req.Context()); err != nil {
+			checks[name] = err.Error()
+			errs = append(errs, err)
+			return
+		}
+		checks[name] = "ok"
+	})
+	checksJSON, err := json.Marshal(checks)
+	if err != nil {
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(checks)")
+		respond(w, req, eW, http.StatusInternalServerError, resp)
+		return
+	}
+	if len(errs) > 0 {
+		err = errors.Join(errs...)
+		wrapError(resp, "Not ready", errStorageUnhealthy)
+		resp.Data = checksJSON
+		eW.LogError(err, "t.Health(req.Context())")
+		respond(w, req, eW, http.StatusServiceUnavailable, resp)
+		return
+	}
+	resp.Wrap("ok", checksJSON, "")
+	respond(w, req, eW, http.StatusOK, resp)
+}
@@ -1,55 +1,173 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
-	"NotesServer/gates/storage"
+	"log/slog"
+	"NotesServer/gates/storage/tenant"
 	"NotesServer/models/dto"
 	"NotesServer/pkg"
+	"NotesServer/pkg/auth"
+	"NotesServer/pkg/validate"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
 type HttpServer struct {
-	srv http.Server
-	st  storage.Storage
+	srv     http.Server
+	tenants *tenant.Registry[*Tenant]
+	legacy  bool
+	auth    *auth.Authenticator
+	policy  *auth.Policy
+	limits  Limits
+	logger  *slog.Logger
+
+	metricsOn bool
+	metrics   *httpMetrics
+	openapi   []byte // описание API, см. openapi.go
+
+	ready atomic.Bool // см. SetReady и /readyz
 }
 
-func NewHttpServer(addr string, st storage.Storage) *HttpServer {
+// Limits - ограничения размера запросов и ответов. Нулевое поле - значение по умолчанию.
+type Limits struct {
+	MaxHeaderBytes int   // размер заголовков запроса (по умолчанию http.DefaultMaxHeaderBytes)
+	MaxBodyBytes   int64 // размер тела запроса; при превышении - 413 (по умолчанию 1 МБ)
+	MaxPageSize    int   // наибольшее значение параметра limit (по умолчанию 1000)
+}
+
+// Timeouts - таймауты соединения, см. одноименные поля http.Server. Нулевое поле - без таймаута.
+type Timeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// Option настраивает HttpServer при создании.
+type Option func(hs *HttpServer)
+
+// WithLegacyEndpoints включает старые POST-эндпоинты (/create, /get, /update, /delete, /get-all)
+// рядом с REST-ресурсом /notes, чтобы существующие клиенты продолжали работать.
+func WithLegacyEndpoints(enabled bool) Option {
+	return func(hs *HttpServer) {
+		hs.legacy = enabled
+	}
+}
+
+// WithLimits задает ограничения размера запросов и ответов.
+func WithLimits(l Limits) Option {
+	return func(hs *HttpServer) {
+		hs.limits = l
+	}
+}
+
+// WithTimeouts задает таймауты соединения.
+func WithTimeouts(t Timeouts) Option {
+	return func(hs *HttpServer) {
+		hs.srv.ReadTimeout = t.Read
+		hs.srv.ReadHeaderTimeout = t.ReadHeader
+		hs.srv.WriteTimeout = t.Write
+		hs.srv.IdleTimeout = t.Idle
+	}
+}
+
+// NewHttpServer создает сервер; данные каждого арендатора берутся из tenants.
+func NewHttpServer(addr string, tenants *tenant.Registry[*Tenant], opts ...Option) *HttpServer {
 	hs := &HttpServer{
-		srv: http.Server{Addr: addr},
-		st:  st,
+		srv:     http.Server{Addr: addr},
+		tenants: tenants,
+		logger:  slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(hs)
+	}
+	if hs.limits.MaxBodyBytes <= 0 {
+		hs.limits.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if hs.limits.MaxPageSize <= 0 {
+		hs.limits.MaxPageSize = defaultMaxPageSize
+	}
+	hs.srv.MaxHeaderBytes = hs.limits.MaxHeaderBytes
+	hs.srv.ErrorLog = slog.NewLogLogger(hs.logger.Handler(), slog.LevelError)
+
+	var err error
+	if hs.openapi, err = hs.openapiDocument(); err != nil {
+		// openapi.json встроен в программу, поэтому ошибка возможна только при ее сборке.
+		panic(err)
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/create", hs.recordCreateHandler)
-	mux.HandleFunc("/get", hs.recordsGetHandler)
-	mux.HandleFunc("/update", hs.recordUpdateHandler)
-	mux.HandleFunc("/delete", hs.recordDeleteByPhone)
-	mux.HandleFunc("/get-all", hs.recordGetAll)
-	hs.srv.Handler = mux
+	mux.HandleFunc(notesPath, hs.notesHandler)
+	mux.HandleFunc(notesPath+"/", hs.noteHandler)
+	mux.HandleFunc(searchPath, hs.searchHandler)
+	if hs.legacy {
+		mux.HandleFunc("/create", hs.recordCreateHandler)
+		mux.HandleFunc("/get", hs.recordsGetHandler)
+		mux.HandleFunc("/update", hs.recordUpdateHandler)
+		mux.HandleFunc("/delete", hs.recordDeleteByPhone)
+		mux.HandleFunc("/get-all", hs.recordGetAll)
+	}
+	// Права проверяются до выбора арендатора, чтобы запрос без прав не открывал его хранилище.
+	hs.srv.Handler = hs.scope(hs.limitBody(mux))
+	if hs.policy != nil {
+		hs.srv.Handler = hs.authorize(hs.srv.Handler)
+	}
+	if hs.auth != nil {
+		hs.srv.Handler = hs.authenticate(hs.srv.Handler)
+	}
+
+	// Служебные эндпоинты обслуживаются в обход аутентификации и арендаторов.
+	root := http.NewServeMux()
+	root.HandleFunc(healthzPath, hs.healthzHandler)
+	root.HandleFunc(readyzPath, hs.readyzHandler)
+	root.HandleFunc(openapiPath, hs.openapiHandler)
+	root.Handle("/", hs.srv.Handler)
+	hs.srv.Handler = root
+	if hs.metricsOn {
+		hs.metrics = hs.newHTTPMetrics()
+		root.HandleFunc(metricsPath, hs.metricsHandler)
+		hs.srv.Handler = hs.instrument(root)
+	}
+	hs.srv.Handler = hs.logRequests(hs.srv.Handler)
 
 	return hs
 }
 
+// Start принимает соединения, пока сервер не будет остановлен.
+// После Shutdown возвращает nil.
 func (hs *HttpServer) Start() error {
 	eW := pkg.NewEWrapper("(hs *HttpServer) Start()")
 	defer eW.Close()
 
-	if err := hs.srv.ListenAndServe(); err != nil {
+	if err := hs.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return eW.WrapError(err, "hs.srv.ListenAndServe()")
 	}
 	return nil
 }
 
-func (hs *HttpServer) recordCreateHandler(w http.ResponseWriter, req *http.Request) {
-	setHeaders(w)
+// Shutdown перестает принимать новые соединения и ждет завершения начатых запросов.
+// Если ctx завершится раньше, оставшиеся соединения закрываются и возвращается ошибка ctx.
+// С начала остановки /readyz отвечает 503.
+func (hs *HttpServer) Shutdown(ctx context.Context) error {
+	eW := pkg.NewEWrapper("(hs *HttpServer) Shutdown()")
+	defer eW.Close()
 
-	eW, err := pkg.NewEWrapperWithFile("(hs *HttpServer) recordCreateHandler()")
-	if err != nil {
-		log.Println("(hs *HttpServer) recordCreateHandler: NewEWrapperWithFile()", err)
+	hs.SetReady(false)
+	if err := hs.srv.Shutdown(ctx); err != nil {
+		hs.srv.Close()
+		return eW.WrapError(err, "hs.srv.Shutdown(ctx)")
 	}
+	return nil
+}
+
+func (hs *HttpServer) recordCreateHandler(w http.ResponseWriter, req *http.Request) {
+	setJSONHeaders(w)
+
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) recordCreateHandler()")
 	resp := &dto.Response{}
 	defer responseReturn(w, eW, resp)
 
@@ -61,28 +179,33 @@ func (hs *HttpServer) recordCreateHandler(w http.ResponseWriter, req *http.Reque
 	record := dto.NewNote()
 	byteReq, err := io.ReadAll(req.Body)
 	if err != nil {
-		resp.Wrap("Error reading request", nil, err.Error())
+		err = badBody(err)
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error reading request", err)
 		eW.LogError(err, "io.ReadAll(req.Body)")
 		return
 	}
-	err = json.Unmarshal(byteReq, &record)
+	err = badBody(validate.DecodeJSON(byteReq, record))
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
-		eW.LogError(err, "json.Unmarshal(req)")
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "validate.DecodeJSON(byteReq, record)")
 		return
 	}
 
-	if record.Name == "" || record.LastName == "" || record.Note == "" {
-		err = errors.New("required data is missing")
-		resp.Wrap("Required data is missing", nil, err.Error())
-		eW.LogError(err, "json.Unmarshal")
+	if err = checkNote(record); err != nil {
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Validation failed", err)
+		eW.LogError(err, "checkNote(record)")
 		return
 	}
-	record.ID = hs.st.NextIndex()
-	idx, err := hs.st.Add(record)
+	record.Version = 1
+	record.Owner = owner(req)
+	idx, err := notesOf(req.Context()).Add(req.Context(), record)
 
 	if err != nil {
-		resp.Wrap("Error in saving record", nil, err.Error())
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in saving record", err)
 		eW.LogError(err, "hs.db.RecordSave(record)")
 		return
 	}
@@ -92,21 +215,20 @@ func (hs *HttpServer) recordCreateHandler(w http.ResponseWriter, req *http.Reque
 	}
 	idxJson, err := json.Marshal(idxMap)
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		wrapError(resp, "Error JSON", err)
 		eW.LogError(err, "json.Marshal(idx)")
 		return
 	}
 
+	setETag(w, record)
 	resp.Wrap("Successfully added", idxJson, "")
 }
 
 func (hs *HttpServer) recordsGetHandler(w http.ResponseWriter, req *http.Request) {
-	setHeaders(w)
+	setJSONHeaders(w)
 
-	eW, err := pkg.NewEWrapperWithFile("(hs *HttpServer) recordsGetHandler()")
-	if err != nil {
-		log.Println("(hs *HttpServer) recordCreateHandler: NewEWrapperWithFile()", err)
-	}
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) recordsGetHandler()")
 	resp := &dto.Response{}
 	defer responseReturn(w, eW, resp)
 
@@ -118,34 +240,52 @@ func (hs *HttpServer) recordsGetHandler(w http.ResponseWriter, req *http.Request
 	record := dto.NewNote()
 	byteReq, err := io.ReadAll(req.Body)
 	if err != nil {
-		resp.Wrap("Error reading request", nil, err.Error())
+		err = badBody(err)
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error reading request", err)
 		eW.LogError(err, "io.ReadAll(req.Body)")
 		return
 	}
-	err = json.Unmarshal(byteReq, &record)
+	err = badBody(json.Unmarshal(byteReq, &record))
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error JSON", err)
 		eW.LogError(err, "json.Unmarshal(req)")
 		return
 	}
 
 	if record.ID == -1 {
-		err = errors.New("no ID provided")
-		resp.Wrap("No ID provided", nil, err.Error())
+		err = errMissingID
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "No ID provided", err)
 		eW.LogError(err, "No ID provided")
 		return
 	}
 
-	records, status := hs.st.GetByIndex(record.ID)
+	records, status, err := notesOf(req.Context()).GetByIndex(req.Context(), record.ID)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in finding records", err)
+		eW.LogError(err, "notesOf(req.Context()).GetByIndex(req.Context(), record.ID)")
+		return
+	}
 	if !status {
-		resp.Wrap("Error in finding records", nil, errors.New("no records found").Error())
-		eW.LogError(err, "hs.db.RecordsGet(record)")
+		err = errNoRecords
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in finding records", err)
+		eW.LogError(err, "notesOf(req.Context()).GetByIndex(req.Context(), record.ID)")
+		return
+	}
+	setETag(w, records)
+	if notModified(req, records) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	recordsJSON, err := json.Marshal(records)
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		wrapError(resp, "Error JSON", err)
 		eW.LogError(err, "json.Marshal(records)")
 		return
 	}
@@ -154,12 +294,9 @@ func (hs *HttpServer) recordsGetHandler(w http.ResponseWriter, req *http.Request
 }
 
 func (hs *HttpServer) recordUpdateHandler(w http.ResponseWriter, req *http.Request) {
-	setHeaders(w)
+	setJSONHeaders(w)
 
-	eW, err := pkg.NewEWrapperWithFile("(hs *HttpServer) recordUpdateHandler()")
-	if err != nil {
-		log.Println("(hs *HttpServer) recordCreateHandler: NewEWrapperWithFile()", err)
-	}
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) recordUpdateHandler()")
 
 	resp := &dto.Response{}
 	defer responseReturn(w, eW, resp)
@@ -172,41 +309,61 @@ func (hs *HttpServer) recordUpdateHandler(w http.ResponseWriter, req *http.Reque
 	record := dto.NewNote()
 	byteReq, err := io.ReadAll(req.Body)
 	if err != nil {
-		resp.Wrap("Error reading request", nil, err.Error())
+		err = badBody(err)
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error reading request", err)
 		eW.LogError(err, "io.ReadAll(req.Body)")
 		return
 	}
-	err = json.Unmarshal(byteReq, &record)
+	err = badBody(validate.DecodeJSON(byteReq, record))
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
-		eW.LogError(err, "json.Unmarshal(byteReq, &record)")
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "validate.DecodeJSON(byteReq, record)")
 		return
 	}
 
-	if (record.Name == "" && record.LastName == "" && record.Note == "") || record.ID == 0 {
-		err = errors.New("required data is missing")
-		resp.Wrap("Required data is missing", nil, err.Error())
-		eW.LogError(err, "json.Unmarshal")
+	// Пустые поля не меняются, поэтому нужно хотя бы одно непустое; измененная заметка проверяется целиком.
+	if record.ID < 1 {
+		err = validate.Errors{{Field: "id", Code: validate.CodeMin, Message: "must be at least 1"}}
+	} else if record.Name == "" && record.LastName == "" && record.Note == "" {
+		err = errMissingData
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Validation failed", err)
+		eW.LogError(err, "checking record")
 		return
 	}
 
-	hs.st.RemoveByIndex(record.ID)
-	err = hs.st.AddToIndex(record, record.ID)
+	// Меняются только переданные непустые поля, остальные сохраняют прежние значения.
+	updated, err := hs.modifyNote(req, record.ID, func(current *dto.Note) (*dto.Note, error) {
+		updated := *current
+		if record.Name != "" {
+			updated.Name = record.Name
+		}
+		if record.LastName != "" {
+			updated.LastName = record.LastName
+		}
+		if record.Note != "" {
+			updated.Note = record.Note
+		}
+		return &updated, checkNote(&updated)
+	})
 	if err != nil {
-		resp.Wrap("Error in updating record", nil, err.Error())
-		eW.LogError(err, "hs.db.RecordUpdate(record)")
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in updating record", err)
+		eW.LogError(err, "hs.modifyNote(req, record.ID, merge)")
 		return
 	}
+	setETag(w, updated)
 	resp.Wrap("Success", nil, "")
 }
 
 func (hs *HttpServer) recordDeleteByPhone(w http.ResponseWriter, req *http.Request) {
-	setHeaders(w)
+	setJSONHeaders(w)
 
-	eW, err := pkg.NewEWrapperWithFile("(hs *HttpServer) recordDeleteByPhone()")
-	if err != nil {
-		log.Println("(hs *HttpServer) recordCreateHandler: NewEWrapperWithFile()", err)
-	}
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) recordDeleteByPhone()")
 
 	resp := &dto.Response{}
 	defer responseReturn(w, eW, resp)
@@ -214,35 +371,42 @@ func (hs *HttpServer) recordDeleteByPhone(w http.ResponseWriter, req *http.Reque
 	record := dto.NewNote()
 	byteReq, err := io.ReadAll(req.Body)
 	if err != nil {
-		resp.Wrap("Error reading request", nil, err.Error())
+		err = badBody(err)
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error reading request", err)
 		eW.LogError(err, "io.ReadAll(r.Body)")
 		return
 	}
-	err = json.Unmarshal(byteReq, &record)
+	err = badBody(json.Unmarshal(byteReq, &record))
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error JSON", err)
 		eW.LogError(err, "json.Unmarshal(byteReq, &record)")
 		return
 	}
 
 	if record.ID == -1 {
-		err = errors.New("id is missing")
-		resp.Wrap("ID is missing", nil, err.Error())
+		err = errMissingID
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "ID is missing", err)
 		eW.LogError(err, "json.Unmarshal")
 		return
 	}
 
-	hs.st.RemoveByIndex(record.ID)
+	err = hs.removeNote(req, record.ID)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in deleting record", err)
+		eW.LogError(err, "hs.removeNote(req, record.ID)")
+		return
+	}
 	resp.Wrap("Success", nil, "")
 }
 
 func (hs *HttpServer) recordGetAll(w http.ResponseWriter, req *http.Request) {
-	setHeaders(w)
+	setJSONHeaders(w)
 
-	eW, err := pkg.NewEWrapperWithFile("(hs *HttpServer) recordGetAll()")
-	if err != nil {
-		log.Println("(hs *HttpServer) recordCreateHandler: NewEWrapperWithFile()", err)
-	}
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) recordGetAll()")
 
 	resp := &dto.Response{}
 	defer responseReturn(w, eW, resp)
@@ -252,16 +416,35 @@ func (hs *HttpServer) recordGetAll(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	records, status := hs.st.GetAll()
-	if !status {
-		resp.Wrap("Error in finding records", nil, errors.New("no records found").Error())
-		eW.LogError(err, "hs.db.RecordsGetAll()")
+	q, err := parsePageQuery(req.URL.Query(), hs.limits.MaxPageSize)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Invalid query", err)
+		eW.LogError(err, "parsePageQuery(req.URL.Query())")
 		return
 	}
+	records, more, err := hs.listPage(req.Context(), q)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in finding records", err)
+		eW.LogError(err, "hs.listPage(req.Context(), q)")
+		return
+	}
+	if len(records) == 0 {
+		err = errNoRecords
+		w.WriteHeader(errorStatus(err))
+		wrapError(resp, "Error in finding records", err)
+		eW.LogError(err, "hs.listPage(req.Context(), q)")
+		return
+	}
+	if more {
+		setNextLink(w, req, q, records)
+	}
 
 	recordsJSON, err := json.Marshal(records)
 	if err != nil {
-		resp.Wrap("Error JSON", nil, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		wrapError(resp, "Error JSON", err)
 		eW.LogError(err, "json.Marshal(records)")
 		return
 	}
@@ -271,6 +454,11 @@ func (hs *HttpServer) recordGetAll(w http.ResponseWriter, req *http.Request) {
 
 func responseReturn(w http.ResponseWriter, eW *pkg.EWrapper, resp *dto.Response) {
 	errEncode := json.NewEncoder(w).Encode(resp)
+	if errors.Is(errEncode, http.ErrBodyNotAllowed) {
+		// Ответ 304 без тела.
+		eW.Close()
+		return
+	}
 	if errEncode != nil {
 		eW.LogError(errEncode, "json.NewEncoder(w).Encode(resp)")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -279,8 +467,26 @@ func responseReturn(w http.ResponseWriter, eW *pkg.EWrapper, resp *dto.Response)
 	eW.Close()
 }
 
+// defaultMaxBodyBytes - размер тела запроса по умолчанию.
+const defaultMaxBodyBytes = 1 << 20
+
+// limitBody ограничивает размер тела запроса значением hs.limits.MaxBodyBytes.
+func (hs *HttpServer) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(w, req.Body, hs.limits.MaxBodyBytes)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// setJSONHeaders выставляет заголовки CORS и тип содержимого ответа в формате JSON.
+func setJSONHeaders(w http.ResponseWriter) {
+	setHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+}
+
 func setHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "*")
+	// Заголовок Authorization не покрывается "*" и должен быть указан явно.
+	w.Header().Set("Access-Control-Allow-Headers", "*, Authorization")
 }
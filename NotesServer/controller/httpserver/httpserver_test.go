@@ -0,0 +1,103 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/mp"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/models/dto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingStore задерживает Add до закрытия release и запоминает вызов Close.
+type blockingStore struct {
+	storage.Storage[*dto.Note]
+	started chan struct{} // закрывается, когда начался Add
+	release chan struct{}
+	closed  atomic.Bool
+}
+
+func (s *blockingStore) Add(ctx context.Context, value *dto.Note) (int64, error) {
+	close(s.started)
+	<-s.release
+	return s.Storage.Add(ctx, value)
+}
+
+func (s *blockingStore) Close() error {
+	s.closed.Store(true)
+	return s.Storage.Close()
+}
+
+// TestShutdown проверяет, что Shutdown дожидается начатого запроса, а хранилища,
+// закрытые после него (как в main), успевают сохранить результат этого запроса.
+func TestShutdown(t *testing.T) {
+	st := &blockingStore{
+		Storage: mp.NewMap[*dto.Note](),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	tenants := tenant.NewRegistry(func(string) (*Tenant, error) {
+		return NewTenant(st)
+	})
+	hs := NewHttpServer(":0", tenants, WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go hs.srv.Serve(ln)
+
+	respCh := make(chan int, 1)
+	go func() {
+		resp, err := http.Post("http://"+ln.Addr().String()+notesPath, "application/json",
+			strings.NewReader(`{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`))
+		if err != nil {
+			t.Error(err)
+			respCh <- 0
+			return
+		}
+		resp.Body.Close()
+		respCh <- resp.StatusCode
+	}()
+	<-st.started
+
+	shutdownCh := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownCh <- hs.Shutdown(ctx)
+	}()
+	select {
+	case err := <-shutdownCh:
+		t.Fatalf("Shutdown returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(st.release)
+	if code := <-respCh; code != http.StatusCreated {
+		t.Errorf("POST /notes: status %d, want %d", code, http.StatusCreated)
+	}
+	if err := <-shutdownCh; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if st.closed.Load() {
+		t.Fatal("storage closed by Shutdown")
+	}
+
+	if err := tenants.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !st.closed.Load() {
+		t.Error("tenants.Close did not close the storage")
+	}
+	if n := st.Storage.Len(); n != 1 {
+		t.Errorf("storage has %d notes after shutdown, want 1", n)
+	}
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"NotesServer/pkg/logging"
+	"time"
+)
+
+// RequestIDHeader - заголовок с идентификатором запроса. Идентификатор клиента
+// сохраняется, если он допустим (см. logging.RequestID), иначе создается новый;
+// в ответе заголовок возвращается всегда.
+const RequestIDHeader = "X-Request-ID"
+
+// WithLogger задает журнал сервера. По умолчанию используется slog.Default().
+func WithLogger(l *slog.Logger) Option {
+	return func(hs *HttpServer) {
+		hs.logger = l
+	}
+}
+
+// statusRecorder запоминает статус и размер ответа для журнала.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// logRequests присваивает запросу идентификатор, сохраняет в контексте логгер с ним
+// (logging.FromContext) и записывает по завершении запроса его метод, путь, статус и время.
+// Ответы 5xx записываются с уровнем Error, 4xx - Warn, остальные - Info.
+func (hs *HttpServer) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		id := logging.RequestID(req.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := hs.logger.With(slog.String(logging.KeyRequestID, id))
+		rec := &statusRecorder{ResponseWriter: w}
+		logger.Debug("request started", slog.String("method", req.Method), slog.String("path", req.URL.Path))
+		next.ServeHTTP(rec, req.WithContext(logging.NewContext(req.Context(), logger)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(req.Context(), level, "request",
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", req.RemoteAddr))
+	})
+}
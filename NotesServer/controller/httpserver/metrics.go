@@ -0,0 +1,168 @@
+package httpserver
+
+import (
+	"net/http"
+	"NotesServer/gates/storage"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Метрики сервера в формате Prometheus:
+//
+//	GET /metrics
+//
+// notes_http_requests_total{handler,method,code}         - количество запросов
+// notes_http_request_duration_seconds{handler,method}    - гистограмма времени обработки
+// notes_http_request_errors_total{handler,method,class}  - ответы 4xx и 5xx
+// notes_storage_tenants                                  - количество открытых хранилищ арендаторов
+// notes_storage_items                                    - сумма Storage.Len()
+// notes_storage_next_index                               - наибольший Storage.NextIndex()
+// notes_storage_lock_acquired_total{mode}                - захваты блокировки хранилищ (read, write)
+// notes_storage_lock_wait_seconds_total{mode}            - суммарное ожидание блокировки
+//
+// Метрики хранилища суммируются по уже открытым хранилищам арендаторов (для
+// next_index берется максимум): метки tenant нет, потому что эндпоинт не требует
+// аутентификации (его опрашивает Prometheus) и не должен раскрывать имена арендаторов. Статистика блокировки есть только
+// у хранилищ, реализующих storage.LockStatser (mp, list, wal).
+const metricsPath = "/metrics"
+
+// WithMetrics включает сбор метрик и эндпоинт /metrics.
+func WithMetrics(enabled bool) Option {
+	return func(hs *HttpServer) {
+		hs.metricsOn = enabled
+	}
+}
+
+// httpMetrics - метрики запросов сервера.
+type httpMetrics struct {
+	reg      *metrics.Registry
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	errors   *metrics.CounterVec
+}
+
+// newHTTPMetrics регистрирует метрики запросов и хранилищ арендаторов hs.
+func (hs *HttpServer) newHTTPMetrics() *httpMetrics {
+	reg := metrics.NewRegistry()
+	m := &httpMetrics{
+		reg:      reg,
+		requests: reg.NewCounterVec("notes_http_requests_total", "Number of HTTP requests.", "handler", "method", "code"),
+		duration: reg.NewHistogramVec("notes_http_request_duration_seconds", "HTTP request latency in seconds.", nil, "handler", "method"),
+		errors:   reg.NewCounterVec("notes_http_request_errors_total", "Number of HTTP responses with a 4xx or 5xx status.", "handler", "method", "class"),
+	}
+
+	reg.NewGaugeFunc("notes_storage_tenants", "Number of open tenant storages.", nil, func(emit func(float64, ...string)) {
+		n := 0
+		hs.tenants.Each(func(string, *Tenant) { n++ })
+		emit(float64(n))
+	})
+	reg.NewGaugeFunc("notes_storage_items", "Number of notes in all open tenant storages.", nil, func(emit func(float64, ...string)) {
+		var items int64
+		hs.tenants.Each(func(_ string, t *Tenant) { items += t.Store.Len() })
+		emit(float64(items))
+	})
+	reg.NewGaugeFunc("notes_storage_next_index", "Largest next note id among open tenant storages.", nil, func(emit func(float64, ...string)) {
+		var next int64
+		hs.tenants.Each(func(_ string, t *Tenant) { next = max(next, t.Store.NextIndex()) })
+		emit(float64(next))
+	})
+
+	lockLabels := []string{"mode"}
+	reg.NewCounterFunc("notes_storage_lock_acquired_total", "Number of times the storage locks were acquired.", lockLabels, func(emit func(float64, ...string)) {
+		var read, write uint64
+		hs.tenants.Each(func(_ string, t *Tenant) {
+			if stats, ok := lockStatsOf(t.Store); ok {
+				read += stats.ReadAcquired
+				write += stats.WriteAcquired
+			}
+		})
+		emit(float64(read), "read")
+		emit(float64(write), "write")
+	})
+	reg.NewCounterFunc("notes_storage_lock_wait_seconds_total", "Total time spent waiting for the storage locks in seconds.", lockLabels, func(emit func(float64, ...string)) {
+		var read, write time.Duration
+		hs.tenants.Each(func(_ string, t *Tenant) {
+			if stats, ok := lockStatsOf(t.Store); ok {
+				read += stats.ReadWait
+				write += stats.WriteWait
+			}
+		})
+		emit(read.Seconds(), "read")
+		emit(write.Seconds(), "write")
+	})
+	return m
+}
+
+// lockStatsOf возвращает статистику блокировки st или вложенного в него хранилища.
+func lockStatsOf(st storage.Storage[*dto.Note]) (storage.LockStats, bool) {
+	if s, ok := storeAs[storage.LockStatser](st); ok {
+		return s.LockStats(), true
+	}
+	return storage.LockStats{}, false
+}
+
+// route возвращает имя обработчика запроса для метки handler. id заметки заменяется
+// на {id}, а неизвестные пути объединяются в "other", чтобы число рядов не росло.
+func route(req *http.Request) string {
+	path := req.URL.Path
+	switch {
+	case path == notesPath, path == searchPath,
+		path == metricsPath, path == healthzPath, path == readyzPath, path == openapiPath,
+		path == "/create", path == "/get", path == "/update", path == "/delete", path == "/get-all":
+		return path
+	case strings.HasPrefix(path, notesPath+"/"):
+		return notesPath + "/{id}"
+	default:
+		return "other"
+	}
+}
+
+// methodLabel возвращает метод запроса для метки method. Метод задает клиент,
+// поэтому нестандартные методы объединяются в "other", как неизвестные пути в route.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// instrument считает запросы, их время и ошибки.
+func (hs *HttpServer) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, req)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		handler, method := route(req), methodLabel(req.Method)
+		hs.metrics.requests.With(handler, method, strconv.Itoa(rec.status)).Inc()
+		hs.metrics.duration.With(handler, method).Observe(time.Since(start).Seconds())
+		if rec.status >= 400 {
+			hs.metrics.errors.With(handler, method, strconv.Itoa(rec.status/100)+"xx").Inc()
+		}
+	})
+}
+
+// metricsHandler выводит метрики в текстовом формате Prometheus.
+func (hs *HttpServer) metricsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		methodNotAllowed(w, "GET, HEAD")
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if req.Method == http.MethodHead {
+		return
+	}
+	if _, err := hs.metrics.reg.WriteTo(w); err != nil {
+		eW := newHandlerEWrapper(req, "(hs *HttpServer) metricsHandler()")
+		eW.LogError(err, "hs.metrics.reg.WriteTo(w)")
+	}
+}
@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"net/http/httptest"
+	"NotesServer/pkg/auth"
+	"strings"
+	"testing"
+)
+
+// TestMetricsLabels проверяет, что метка method принимает только известные значения,
+// а /metrics не раскрывает имена арендаторов.
+func TestMetricsLabels(t *testing.T) {
+	keys, err := auth.LoadAPIKeys(writeFile(t, "keys.json", `{"api_keys":[
+		{"name":"alice","key":"ka","tenant":"acme-secret"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := newTestServer(WithAuth(&auth.Authenticator{Keys: keys}))
+
+	for _, method := range []string{"POST", "GET", "BREW", "X-RANDOM-1"} {
+		req := httptest.NewRequest(method, notesPath, strings.NewReader(`{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`))
+		req.Header.Set(auth.APIKeyHeader, "ka")
+		hs.srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := serve(hs, "GET", metricsPath, "")
+	if rec.Code != 200 {
+		t.Fatalf("GET /metrics: status %d, body %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`notes_http_requests_total{handler="/notes",method="POST",code="201"} 1`,
+		`notes_http_requests_total{handler="/notes",method="other",code="405"} 2`,
+		"notes_storage_tenants 1\n",
+		"notes_storage_items 1\n",
+		"notes_storage_next_index 2\n",
+		`notes_storage_lock_acquired_total{mode="write"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics has no %s:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"BREW", "X-RANDOM-1", "acme-secret", "tenant="} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("/metrics contains %s:\n%s", unwanted, body)
+		}
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	tests := []struct {
+		method, want string
+	}{
+		{"GET", "GET"},
+		{"HEAD", "HEAD"},
+		{"POST", "POST"},
+		{"PUT", "PUT"},
+		{"PATCH", "PATCH"},
+		{"DELETE", "DELETE"},
+		{"OPTIONS", "OPTIONS"},
+		{"get", "other"},
+		{"TRACE", "other"},
+		{"PROPFIND", "other"},
+	}
+	for _, tt := range tests {
+		if got := methodLabel(tt.method); got != tt.want {
+			t.Errorf("methodLabel(%q) = %q, want %q", tt.method, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"NotesServer/models/dto"
+	"NotesServer/pkg"
+	"NotesServer/pkg/auth"
+	"NotesServer/pkg/logging"
+	"NotesServer/pkg/mergepatch"
+	"NotesServer/pkg/validate"
+	"strconv"
+	"strings"
+)
+
+// REST-ресурс заметок:
+//
+//	GET    /notes       - список заметок (постранично, с сортировкой и фильтрами, см. page.go)
+//	POST   /notes       - создать заметку (201 и заголовок Location)
+//	DELETE /notes       - удалить все заметки арендатора (204; только с правом auth.PermClear)
+//	GET    /notes/{id}  - заметка по id (ETag, If-None-Match)
+//	PUT    /notes/{id}  - заменить заметку целиком (нужны все поля; If-Match)
+//	PATCH  /notes/{id}  - изменить переданные поля (JSON Merge Patch, RFC 7396; If-Match)
+//	DELETE /notes/{id}  - удалить заметку (204; If-Match)
+const notesPath = "/notes"
+
+// notesHandler обслуживает коллекцию /notes.
+func (hs *HttpServer) notesHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		hs.notesList(w, req)
+	case http.MethodPost:
+		hs.notesCreate(w, req)
+	case http.MethodDelete:
+		hs.notesClear(w, req)
+	case http.MethodOptions:
+		preflight(w, "GET, POST, DELETE, OPTIONS")
+	default:
+		methodNotAllowed(w, "GET, POST, DELETE, OPTIONS")
+	}
+}
+
+// noteHandler обслуживает отдельную заметку /notes/{id}.
+func (hs *HttpServer) noteHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		hs.noteGet(w, req)
+	case http.MethodPut:
+		hs.notePut(w, req)
+	case http.MethodPatch:
+		hs.notePatch(w, req)
+	case http.MethodDelete:
+		hs.noteDelete(w, req)
+	case http.MethodOptions:
+		preflight(w, "GET, PUT, PATCH, DELETE, OPTIONS")
+	default:
+		methodNotAllowed(w, "GET, PUT, PATCH, DELETE, OPTIONS")
+	}
+}
+
+func (hs *HttpServer) notesList(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) notesList()")
+	status, resp := http.StatusOK, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	q, err := parsePageQuery(req.URL.Query(), hs.limits.MaxPageSize)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Invalid query", err)
+		eW.LogError(err, "parsePageQuery(req.URL.Query())")
+		return
+	}
+	records, more, err := hs.listPage(req.Context(), q)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in finding records", err)
+		eW.LogError(err, "hs.listPage(req.Context(), q)")
+		return
+	}
+	if records == nil {
+		records = []*dto.Note{}
+	}
+	if more {
+		setNextLink(w, req, q, records)
+	}
+
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		status = http.StatusInternalServerError
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(records)")
+		return
+	}
+	resp.Wrap("Success", recordsJSON, "")
+}
+
+func (hs *HttpServer) notesCreate(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) notesCreate()")
+	status, resp := http.StatusCreated, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	record, err := readNote(req)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "readNote(req)")
+		return
+	}
+	if err = checkNote(record); err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Validation failed", err)
+		eW.LogError(err, "checkNote(record)")
+		return
+	}
+
+	// Индекс заметке присваивает хранилище в Add; после Add заметку уже читают другие запросы.
+	record.Version = 1
+	record.Owner = owner(req)
+	idx, err := notesOf(req.Context()).Add(req.Context(), record)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in saving record", err)
+		eW.LogError(err, "notesOf(req.Context()).Add(req.Context(), record)")
+		return
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		status = http.StatusInternalServerError
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(record)")
+		return
+	}
+	w.Header().Set("Location", notePath(idx))
+	setETag(w, record)
+	resp.Wrap("Successfully added", recordJSON, "")
+}
+
+func (hs *HttpServer) noteGet(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) noteGet()")
+	status, resp := http.StatusOK, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	id, err := noteID(req)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Invalid ID", err)
+		eW.LogError(err, "noteID(req)")
+		return
+	}
+
+	record, ok, err := notesOf(req.Context()).GetByIndex(req.Context(), id)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in finding records", err)
+		eW.LogError(err, "notesOf(req.Context()).GetByIndex(req.Context(), id)")
+		return
+	}
+	if !ok {
+		err = errNoRecords
+		status = errorStatus(err)
+		wrapError(resp, "Error in finding records", err)
+		return
+	}
+	setETag(w, record)
+	if notModified(req, record) {
+		status = http.StatusNotModified
+		return
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		status = http.StatusInternalServerError
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(record)")
+		return
+	}
+	resp.Wrap("Success", recordJSON, "")
+}
+
+// notePut заменяет заметку целиком; нужны все поля. id в теле, если указан, должен совпадать с id в пути.
+// С заголовком If-Match заметка заменяется, только если ее версия не изменилась.
+func (hs *HttpServer) notePut(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) notePut()")
+	status, resp := http.StatusOK, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	id, err := noteID(req)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Invalid ID", err)
+		eW.LogError(err, "noteID(req)")
+		return
+	}
+	record, err := readNote(req)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "readNote(req)")
+		return
+	}
+	// Как и в PATCH, id в теле можно не указывать, но изменить его нельзя.
+	if record.ID != id && record.ID != dto.NewNote().ID {
+		err = idChangeError()
+		status = errorStatus(err)
+		wrapError(resp, "Validation failed", err)
+		eW.LogError(err, "record.ID != id")
+		return
+	}
+	if err = checkNote(record); err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Validation failed", err)
+		eW.LogError(err, "checkNote(record)")
+		return
+	}
+
+	updated, err := hs.modifyNote(req, id, func(*dto.Note) (*dto.Note, error) {
+		return record, nil
+	})
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in updating record", err)
+		eW.LogError(err, "hs.modifyNote(req, id, replace)")
+		return
+	}
+
+	recordJSON, err := json.Marshal(updated)
+	if err != nil {
+		status = http.StatusInternalServerError
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(updated)")
+		return
+	}
+	setETag(w, updated)
+	resp.Wrap("Success", recordJSON, "")
+}
+
+// notePatch изменяет только переданные поля заметки по JSON Merge Patch (RFC 7396).
+// Поле со значением null очищается. С заголовком If-Match заметка изменяется,
+// только если ее версия не изменилась.
+func (hs *HttpServer) notePatch(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) notePatch()")
+	status, resp := http.StatusOK, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	id, err := noteID(req)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Invalid ID", err)
+		eW.LogError(err, "noteID(req)")
+		return
+	}
+	if ct := mediaType(req); ct != "" && ct != mergepatch.ContentType && ct != "application/json" {
+		err = fmt.Errorf("%w %q, use %s", errUnsupportedMediaType, ct, mergepatch.ContentType)
+		status = errorStatus(err)
+		wrapError(resp, "Unsupported content type", err)
+		eW.LogError(err, "mediaType(req)")
+		return
+	}
+	patch, err := io.ReadAll(req.Body)
+	if err != nil {
+		err = badBody(err)
+		status = errorStatus(err)
+		wrapError(resp, "Error reading request", err)
+		eW.LogError(err, "io.ReadAll(req.Body)")
+		return
+	}
+
+	updated, err := hs.modifyNote(req, id, func(current *dto.Note) (*dto.Note, error) {
+		return patchNote(current, patch)
+	})
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in updating record", err)
+		eW.LogError(err, "hs.modifyNote(req, id, patchNote)")
+		return
+	}
+
+	recordJSON, err := json.Marshal(updated)
+	if err != nil {
+		status = http.StatusInternalServerError
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(updated)")
+		return
+	}
+	setETag(w, updated)
+	resp.Wrap("Success", recordJSON, "")
+}
+
+// noteDelete удаляет заметку. С заголовком If-Match заметка удаляется,
+// только если ее версия не изменилась.
+func (hs *HttpServer) noteDelete(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) noteDelete()")
+	status, resp := http.StatusNoContent, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	id, err := noteID(req)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Invalid ID", err)
+		eW.LogError(err, "noteID(req)")
+		return
+	}
+
+	if err = hs.removeNote(req, id); err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in deleting record", err)
+		eW.LogError(err, "hs.removeNote(req, id)")
+		return
+	}
+}
+
+// notesClear удаляет все заметки арендатора. Нужно право auth.PermClear, поэтому без
+// политики доступа (WithPolicy) очистка запрещена всем.
+func (hs *HttpServer) notesClear(w http.ResponseWriter, req *http.Request) {
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) notesClear()")
+	if !hs.allowed(req, auth.PermClear) {
+		forbidden(w, req, eW)
+		return
+	}
+	status, resp := http.StatusNoContent, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	if err := notesOf(req.Context()).Clear(req.Context()); err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Error in clearing records", err)
+		eW.LogError(err, "notesOf(req.Context()).Clear(req.Context())")
+		return
+	}
+}
+
+// readNote читает заметку из тела запроса. Ошибки оборачиваются в bodyError,
+// неизвестные поля возвращаются как validate.Errors.
+func readNote(req *http.Request) (*dto.Note, error) {
+	record := dto.NewNote()
+	byteReq, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, badBody(err)
+	}
+	if err := validate.DecodeJSON(byteReq, record); err != nil {
+		return nil, badBody(err)
+	}
+	return record, nil
+}
+
+// checkNote проверяет поля заметки по тегам validate dto.Note и возвращает validate.Errors.
+func checkNote(record *dto.Note) error {
+	return validate.Struct(record)
+}
+
+// patchNote применяет JSON Merge Patch к копии заметки current.
+// current не изменяется: ее может одновременно читать другой запрос.
+// id заметки изменить нельзя; результат должен пройти checkNote.
+func patchNote(current *dto.Note, patch []byte) (*dto.Note, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadPatch, err)
+	}
+
+	updated := dto.NewNote()
+	if err := validate.DecodeJSON(merged, updated); err != nil {
+		if fieldErrors(err) != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errBadPatch, err)
+	}
+	if updated.ID != current.ID && updated.ID != dto.NewNote().ID {
+		return nil, idChangeError()
+	}
+	updated.ID = current.ID
+
+	if err := checkNote(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// mediaType возвращает тип содержимого запроса без параметров.
+func mediaType(req *http.Request) string {
+	ct, _, _ := strings.Cut(req.Header.Get("Content-Type"), ";")
+	return strings.TrimSpace(strings.ToLower(ct))
+}
+
+// noteID возвращает id заметки из пути /notes/{id}.
+func noteID(req *http.Request) (int64, error) {
+	raw := strings.TrimPrefix(req.URL.Path, notesPath+"/")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 1 {
+		return 0, errInvalidID
+	}
+	return id, nil
+}
+
+func notePath(id int64) string {
+	return notesPath + "/" + strconv.FormatInt(id, 10)
+}
+
+// newHandlerEWrapper создает EWrapper, записывающий ошибки в журнал запроса req.
+func newHandlerEWrapper(req *http.Request, f string) *pkg.EWrapper {
+	return pkg.NewEWrapperWithLogger(f, logging.FromContext(req.Context()))
+}
+
+// respond записывает статус и тело ответа. Для 204 и 304 тело не пишется.
+// Ошибка с кодом записывается как problem+json, если клиент его принимает; ответы
+// с данными (например, проверки /readyz) всегда остаются в формате dto.Response.
+func respond(w http.ResponseWriter, req *http.Request, eW *pkg.EWrapper, status int, resp *dto.Response) {
+	defer eW.Close()
+
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		setHeaders(w)
+		w.WriteHeader(status)
+		return
+	}
+	if status >= http.StatusBadRequest && resp.Code != "" && resp.Data == nil && acceptsProblem(req) {
+		if err := writeProblem(w, status, newProblem(w, req, status, resp)); err != nil {
+			eW.LogError(err, "writeProblem(w, status, problem)")
+		}
+		return
+	}
+	setJSONHeaders(w)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		eW.LogError(err, "json.NewEncoder(w).Encode(resp)")
+	}
+}
+
+func preflight(w http.ResponseWriter, allow string) {
+	setHeaders(w)
+	w.Header().Set("Allow", allow)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	setHeaders(w)
+	w.Header().Set("Allow", allow)
+	http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+}
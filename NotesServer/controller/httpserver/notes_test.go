@@ -0,0 +1,215 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"NotesServer/models/dto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// serve выполняет запрос к серверу hs и возвращает ответ.
+func serve(hs *HttpServer, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	hs.srv.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestNotesCreateConcurrent создает заметки параллельно через REST и старый эндпоинт
+// и проверяет, что id каждой сохраненной заметки совпадает с ее индексом в хранилище
+// и в поисковом индексе. Одновременно идут чтения списка, поэтому -race замечает
+// изменение заметки, уже переданной хранилищу.
+func TestNotesCreateConcurrent(t *testing.T) {
+	const n = 200
+	hs := newTestServer()
+
+	var wg sync.WaitGroup
+	ids := make(chan int64, 2*n)
+	for i := 0; i < n; i++ {
+		wg.Add(3)
+		// Чтение списка одновременно с созданием: заметку нельзя менять после Add.
+		go func() {
+			defer wg.Done()
+			serve(hs, "GET", notesPath+"?limit=1000", "")
+		}()
+		go func(i int) {
+			defer wg.Done()
+			rec := serve(hs, "POST", notesPath, fmt.Sprintf(`{"name":"Ivan","last_name":"Petrov","note":"rest%d"}`, i))
+			var resp struct{ Data dto.Note }
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != 201 {
+				t.Errorf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+				return
+			}
+			if loc := rec.Header().Get("Location"); loc != notePath(resp.Data.ID) {
+				t.Errorf("POST /notes: Location %s, id %d", loc, resp.Data.ID)
+			}
+			ids <- resp.Data.ID
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			rec := serve(hs, "POST", "/create", fmt.Sprintf(`{"name":"Ivan","last_name":"Petrov","note":"legacy%d"}`, i))
+			var resp struct{ Data struct{ ID int64 } }
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != 200 {
+				t.Errorf("POST /create: status %d, body %s", rec.Code, rec.Body)
+				return
+			}
+			ids <- resp.Data.ID
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("id %d returned twice", id)
+		}
+		seen[id] = true
+
+		rec := serve(hs, "GET", notePath(id), "")
+		var resp struct{ Data dto.Note }
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != 200 || resp.Data.ID != id {
+			t.Errorf("GET %s: status %d, stored id %d", notePath(id), rec.Code, resp.Data.ID)
+			continue
+		}
+
+		rec = serve(hs, "GET", searchPath+"?q="+resp.Data.Note, "")
+		var hits struct{ Data []dto.SearchHit }
+		json.Unmarshal(rec.Body.Bytes(), &hits)
+		if len(hits.Data) != 1 || hits.Data[0].Note.ID != id {
+			t.Errorf("search %q: %s", resp.Data.Note, rec.Body)
+		}
+	}
+	if len(seen) != 2*n {
+		t.Errorf("%d notes created, want %d", len(seen), 2*n)
+	}
+}
+
+// TestNoteIDNotReused проверяет, что после удаления последней заметки новая получает
+// другой id, поэтому устаревший If-Match не может изменить чужую заметку.
+func TestNoteIDNotReused(t *testing.T) {
+	hs := newTestServer()
+	const note = `{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`
+
+	rec := serve(hs, "POST", notesPath, note)
+	var created struct{ Data dto.Note }
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != 201 {
+		t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+	}
+	old := created.Data.ID
+	if rec := serve(hs, "DELETE", notePath(old), ""); rec.Code != 204 {
+		t.Fatalf("DELETE %s: status %d, body %s", notePath(old), rec.Code, rec.Body)
+	}
+
+	rec = serve(hs, "POST", notesPath, `{"name":"Anna","last_name":"Sidorova","note":"call mom"}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != 201 {
+		t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+	}
+	if created.Data.ID == old {
+		t.Fatalf("deleted id %d reused", old)
+	}
+
+	req := httptest.NewRequest("PUT", notePath(old), strings.NewReader(note))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag(1))
+	rec = httptest.NewRecorder()
+	hs.srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != 404 {
+		t.Errorf("PUT %s with stale If-Match: status %d, want 404", notePath(old), rec.Code)
+	}
+}
+
+// TestSearchSync проверяет, что поиск видит замену, изменение и удаление заметок
+// через REST: индекс обновляет NoteIndexer, подключенный к хранилищу.
+func TestSearchSync(t *testing.T) {
+	hs := newTestServer()
+	rec := serve(hs, "POST", notesPath, `{"name":"Ivan","last_name":"Petrov","note":"купить молоко"}`)
+	if rec.Code != 201 {
+		t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+	}
+	path := rec.Header().Get("Location")
+
+	steps := []struct {
+		method, body string
+		query        string
+		found        bool
+	}{
+		{"", "", "молоко", true},
+		{"PUT", `{"name":"Ivan","last_name":"Petrov","note":"позвонить маме"}`, "молоко", false},
+		{"", "", "маме", true},
+		{"PATCH", `{"last_name":"Сёмин"}`, "семин", true},
+		{"", "", "petrov", false},
+		{"DELETE", "", "маме", false},
+	}
+	for _, s := range steps {
+		if s.method != "" {
+			req := httptest.NewRequest(s.method, path, strings.NewReader(s.body))
+			if s.method == "PATCH" {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			} else if s.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+			hs.srv.Handler.ServeHTTP(rec, req)
+			if rec.Code >= 300 {
+				t.Fatalf("%s %s: status %d, body %s", s.method, path, rec.Code, rec.Body)
+			}
+		}
+		rec := serve(hs, "GET", searchPath+"?q="+s.query, "")
+		var hits struct{ Data []dto.SearchHit }
+		json.Unmarshal(rec.Body.Bytes(), &hits)
+		if found := len(hits.Data) == 1; found != s.found {
+			t.Errorf("after %s: search %q: %s", s.method, s.query, rec.Body)
+		}
+	}
+}
+
+// TestNoteIDChange проверяет, что PUT и PATCH одинаково отклоняют id в теле,
+// отличный от id в пути: 422, код id_change и ошибка поля id.
+func TestNoteIDChange(t *testing.T) {
+	hs := newTestServer()
+	if rec := serve(hs, "POST", notesPath, `{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`); rec.Code != 201 {
+		t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		method, body string
+		want         int
+	}{
+		{"PUT", `{"id":2,"name":"Ivan","last_name":"Petrov","note":"buy bread"}`, 422},
+		{"PUT", `{"id":0,"name":"Ivan","last_name":"Petrov","note":"buy bread"}`, 422},
+		{"PATCH", `{"id":2}`, 422},
+		{"PUT", `{"id":1,"name":"Ivan","last_name":"Petrov","note":"buy bread"}`, 200},
+		{"PUT", `{"name":"Ivan","last_name":"Petrov","note":"buy tea"}`, 200},
+		{"PATCH", `{"id":1,"note":"buy milk"}`, 200},
+	}
+	for _, tt := range tests {
+		rec := serve(hs, tt.method, notePath(1), tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d, body %s", tt.method, tt.body, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.want != 422 {
+			continue
+		}
+		var resp dto.Response
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Code != codeIDChange || len(resp.Errors) != 1 || resp.Errors[0].Field != "id" || resp.Errors[0].Code != codeIDChange {
+			t.Errorf("%s %s: code %q, errors %+v", tt.method, tt.body, resp.Code, resp.Errors)
+		}
+	}
+
+	rec := serve(hs, "GET", notePath(1), "")
+	var got struct{ Data dto.Note }
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if got.Data.ID != 1 || got.Data.Note != "buy milk" {
+		t.Errorf("stored note %+v", got.Data)
+	}
+}
@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Описание API в формате OpenAPI 3:
+//
+//	GET /openapi.json
+//
+// Документ хранится в openapi.json и описывает все эндпоинты, которые может
+// зарегистрировать NewHttpServer. Отключенные эндпоинты (старые POST-эндпоинты без
+// WithLegacyEndpoints, /metrics без WithMetrics) из отдаваемого документа убираются.
+// Соответствие ответов обработчиков документу проверяет openapi_test.go.
+// Эндпоинт не требует аутентификации.
+const openapiPath = "/openapi.json"
+
+//go:embed openapi.json
+var openapiSpec []byte
+
+// legacyPaths - старые POST-эндпоинты, см. WithLegacyEndpoints.
+var legacyPaths = []string{"/create", "/get", "/update", "/delete", "/get-all"}
+
+// openapiDocument возвращает описание API с эндпоинтами, включенными в hs.
+func (hs *HttpServer) openapiDocument() ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(openapiSpec, &doc); err != nil {
+		return nil, fmt.Errorf("httpserver: decode openapi.json: %w", err)
+	}
+	paths, ok := doc["paths"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("httpserver: openapi.json has no paths")
+	}
+	if !hs.legacy {
+		for _, path := range legacyPaths {
+			delete(paths, path)
+		}
+	}
+	if !hs.metricsOn {
+		delete(paths, metricsPath)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (hs *HttpServer) openapiHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		methodNotAllowed(w, "GET, HEAD")
+		return
+	}
+	setHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(hs.openapi); err != nil {
+		eW := newHandlerEWrapper(req, "(hs *HttpServer) openapiHandler()")
+		eW.LogError(err, "w.Write(hs.openapi)")
+	}
+}
@@ -0,0 +1,504 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"NotesServer/gates/storage/mp"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/models/dto"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// spec - разобранный openapi.json с проверкой ответов и запросов по его схемам.
+// Поддерживается подмножество OpenAPI 3.0, которое используется в документе.
+type spec struct {
+	doc     map[string]any
+	covered map[string]bool // "METHOD шаблон-пути" вызванных операций
+}
+
+func loadSpec(t *testing.T, raw []byte) *spec {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Fatalf("openapi.json: openapi = %q, want 3.x", v)
+	}
+	return &spec{doc: doc, covered: make(map[string]bool)}
+}
+
+func (s *spec) paths() map[string]any {
+	return s.doc["paths"].(map[string]any)
+}
+
+// resolve возвращает объект, на который ссылается $ref в node, или сам node.
+func (s *spec) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var cur any = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			cur = cur.(map[string]any)[part]
+		}
+		node = cur.(map[string]any)
+	}
+}
+
+// template возвращает шаблон пути документа, которому соответствует path.
+func (s *spec) template(path string) (string, bool) {
+	if _, ok := s.paths()[path]; ok {
+		return path, true
+	}
+	segs := strings.Split(path, "/")
+	for tmpl := range s.paths() {
+		tsegs := strings.Split(tmpl, "/")
+		if len(tsegs) != len(segs) {
+			continue
+		}
+		match := true
+		for i := range tsegs {
+			if tsegs[i] != segs[i] && !strings.HasPrefix(tsegs[i], "{") {
+				match = false
+				break
+			}
+		}
+		if match {
+			return tmpl, true
+		}
+	}
+	return "", false
+}
+
+// operation возвращает описание операции method над path.
+func (s *spec) operation(method, path string) (map[string]any, string, error) {
+	tmpl, ok := s.template(path)
+	if !ok {
+		return nil, "", fmt.Errorf("path %s is not documented", path)
+	}
+	op, ok := s.paths()[tmpl].(map[string]any)[strings.ToLower(method)].(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("%s %s is not documented", method, tmpl)
+	}
+	return op, tmpl, nil
+}
+
+// checkRequest проверяет тело запроса по схеме requestBody операции.
+func (s *spec) checkRequest(op map[string]any, contentType string, body []byte) []error {
+	rb, ok := op["requestBody"].(map[string]any)
+	if !ok {
+		if len(body) > 0 {
+			return []error{fmt.Errorf("request body is not documented")}
+		}
+		return nil
+	}
+	media, err := s.media(s.resolve(rb), contentType)
+	if err != nil {
+		return []error{fmt.Errorf("request: %v", err)}
+	}
+	return s.checkJSON(media, body, "request")
+}
+
+// checkResponse проверяет статус, обязательные заголовки и тело ответа по документу.
+func (s *spec) checkResponse(op map[string]any, res *http.Response, body []byte) []error {
+	responses := op["responses"].(map[string]any)
+	node, ok := responses[fmt.Sprint(res.StatusCode)].(map[string]any)
+	if !ok {
+		if node, ok = responses["default"].(map[string]any); !ok {
+			return []error{fmt.Errorf("status %d is not documented", res.StatusCode)}
+		}
+	}
+	resp := s.resolve(node)
+
+	var errs []error
+	headers, _ := resp["headers"].(map[string]any)
+	for name, h := range headers {
+		if s.resolve(h.(map[string]any))["required"] == true && res.Header.Get(name) == "" {
+			errs = append(errs, fmt.Errorf("required header %s is missing", name))
+		}
+	}
+
+	if _, ok := resp["content"]; !ok {
+		if len(body) > 0 {
+			errs = append(errs, fmt.Errorf("status %d has no documented body, got %q", res.StatusCode, body))
+		}
+		return errs
+	}
+	media, err := s.media(resp, res.Header.Get("Content-Type"))
+	if err != nil {
+		return append(errs, err)
+	}
+	return append(errs, s.checkJSON(media, body, "response")...)
+}
+
+// media возвращает описание тела node с типом содержимого contentType.
+func (s *spec) media(node map[string]any, contentType string) (map[string]any, error) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("content type %q: %v", contentType, err)
+	}
+	media, ok := node["content"].(map[string]any)[mt].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("content type %s is not documented", mt)
+	}
+	return media, nil
+}
+
+// checkJSON проверяет JSON-тело по схеме media; тела других типов не проверяются.
+func (s *spec) checkJSON(media map[string]any, body []byte, where string) []error {
+	schema, ok := media["schema"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	if t, _ := s.resolve(schema)["type"].(string); t == "string" {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []error{fmt.Errorf("%s is not JSON: %v", where, err)}
+	}
+	return s.validate(schema, v, where)
+}
+
+// validate проверяет значение v по схеме schema; at - место v в документе для сообщений.
+func (s *spec) validate(schema map[string]any, v any, at string) []error {
+	schema = s.resolve(schema)
+	if v == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return []error{fmt.Errorf("%s: null is not allowed", at)}
+	}
+
+	var errs []error
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			errs = append(errs, s.validate(sub.(map[string]any), v, at)...)
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if e == v {
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("%s: %v is not one of %v", at, v, enum))
+		}
+	}
+
+	switch schema["type"] {
+	case nil:
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want object, got %T", at, v))
+		}
+		props, _ := schema["properties"].(map[string]any)
+		if req, ok := schema["required"].([]any); ok {
+			for _, name := range req {
+				if _, ok := obj[name.(string)]; !ok {
+					errs = append(errs, fmt.Errorf("%s: required property %s is missing", at, name))
+				}
+			}
+		}
+		for name, value := range obj {
+			if p, ok := props[name].(map[string]any); ok {
+				errs = append(errs, s.validate(p, value, at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					errs = append(errs, fmt.Errorf("%s: property %s is not documented", at, name))
+				}
+			case map[string]any:
+				errs = append(errs, s.validate(extra, value, at+"."+name)...)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want array, got %T", at, v))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range arr {
+				errs = append(errs, s.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want string, got %T", at, v))
+		}
+		n := float64(utf8.RuneCountInString(str))
+		if min, ok := schema["minLength"].(float64); ok && n < min {
+			errs = append(errs, fmt.Errorf("%s: %q is shorter than %v", at, str, min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && n > max {
+			errs = append(errs, fmt.Errorf("%s: %q is longer than %v", at, str, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(str) {
+			errs = append(errs, fmt.Errorf("%s: %q does not match %s", at, str, pattern))
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want %s, got %T", at, schema["type"], v))
+		}
+		if schema["type"] == "integer" && n != float64(int64(n)) {
+			errs = append(errs, fmt.Errorf("%s: %v is not an integer", at, n))
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			errs = append(errs, fmt.Errorf("%s: %v is less than %v", at, n, min))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			errs = append(errs, fmt.Errorf("%s: want boolean, got %T", at, v))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s: unsupported schema type %v", at, schema["type"]))
+	}
+	return errs
+}
+
+// newTestServer возвращает сервер со всеми эндпоинтами и хранилищами в памяти, без аутентификации.
+func newTestServer(opts ...Option) *HttpServer {
+	tenants := tenant.NewRegistry(func(string) (*Tenant, error) {
+		return NewTenant(mp.NewMap[*dto.Note]())
+	})
+	opts = append([]Option{
+		WithLegacyEndpoints(true),
+		WithMetrics(true),
+		WithLogger(slog.New(slog.NewJSONHandler(io.Discard, nil))),
+	}, opts...)
+	return NewHttpServer(":0", tenants, opts...)
+}
+
+// TestOpenAPIConformance вызывает все операции документа и проверяет, что настоящие
+// ответы обработчиков (статус, заголовки и тело) соответствуют openapi.json.
+func TestOpenAPIConformance(t *testing.T) {
+	hs := newTestServer()
+	hs.SetReady(true)
+	s := loadSpec(t, hs.openapi)
+
+	const note = `{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`
+	problemJSON := map[string]string{"Accept": ProblemContentType}
+	steps := []struct {
+		method, path string
+		header       map[string]string
+		body         string
+		want         int
+		fields       []string // поля, которые должны быть в errors ответа 422
+		code         string   // ожидаемый код ошибки
+		before       func()
+	}{
+		{method: "GET", path: "/healthz", want: 200},
+		{method: "GET", path: "/readyz", want: 200},
+		{method: "GET", path: "/openapi.json", want: 200},
+
+		{method: "POST", path: "/notes", body: note, want: 201},
+		{method: "POST", path: "/notes", body: `{"name":"Anna","last_name":"Sidorova","note":"call mom"}`, want: 201},
+		{method: "POST", path: "/notes", body: `{"name":"Ivan"}`, want: 422, fields: []string{"last_name", "note"}},
+		{method: "POST", path: "/notes", header: problemJSON, body: `{"name":"Ivan"}`, want: 422, fields: []string{"last_name", "note"}},
+		{method: "POST", path: "/notes", body: `{"name":"  ","last_name":"Petrov","note":"x"}`, want: 422, fields: []string{"name"}},
+		{method: "POST", path: "/notes", body: `{"name":"Ivan","last_name":"Petrov","note":"` + strings.Repeat("я", 10001) + `"}`, want: 422, fields: []string{"note"}},
+		{method: "POST", path: "/notes", body: `{"name":"Ivan","last_name":"Petrov","note":"x","color":"red"}`, want: 422, fields: []string{"color"}},
+		{method: "POST", path: "/notes", body: `{`, want: 400, code: codeInvalidBody},
+		{method: "GET", path: "/notes", want: 200},
+		{method: "GET", path: "/notes?limit=1&sort=-name", want: 200},
+		{method: "GET", path: "/notes?sort=owner", want: 400, code: codeInvalidQuery},
+		{method: "OPTIONS", path: "/notes", want: 204},
+
+		{method: "GET", path: "/notes/1", want: 200},
+		{method: "GET", path: "/notes/1", header: map[string]string{"If-None-Match": `"1"`}, want: 304},
+		{method: "GET", path: "/notes/99", want: 404, code: codeNotFound},
+		{method: "GET", path: "/notes/99", header: problemJSON, want: 404, code: codeNotFound},
+		{method: "GET", path: "/notes/abc", want: 400, code: codeInvalidID},
+		{method: "PUT", path: "/notes/1", body: note, want: 200},
+		{method: "PUT", path: "/notes/1", body: `{"name":"Ivan","last_name":"\t","note":"x"}`, want: 422, fields: []string{"last_name"}},
+		{method: "PUT", path: "/notes/1", header: map[string]string{"If-Match": `"42"`}, body: note, want: 412, code: codePreconditionFailed},
+		{method: "PATCH", path: "/notes/1", header: map[string]string{"Content-Type": "application/merge-patch+json"}, body: `{"note":"buy bread"}`, want: 200},
+		{method: "PATCH", path: "/notes/1", header: map[string]string{"Content-Type": "text/plain"}, body: `{"note":"x"}`, want: 415, code: codeUnsupportedMediaType},
+		{method: "PATCH", path: "/notes/1", header: map[string]string{"Content-Type": "application/json"}, body: `{"note":null}`, want: 422, fields: []string{"note"}},
+		{method: "PATCH", path: "/notes/1", header: map[string]string{"Content-Type": "application/json"}, body: `{"title":"x"}`, want: 422, fields: []string{"title"}},
+		{method: "OPTIONS", path: "/notes/1", want: 204},
+
+		{method: "GET", path: "/search?q=bread", want: 200},
+		{method: "GET", path: "/search", want: 400, code: codeInvalidQuery},
+		{method: "OPTIONS", path: "/search", want: 204},
+
+		{method: "POST", path: "/create", body: note, want: 200},
+		{method: "POST", path: "/create", body: `{"name":"Ivan","last_name":"Petrov","note":" "}`, want: 422, fields: []string{"note"}},
+		{method: "POST", path: "/create", body: `{"name":`, want: 400, code: codeInvalidBody},
+		{method: "POST", path: "/get", body: `{"id":1}`, want: 200},
+		{method: "POST", path: "/get", body: `{"id":99}`, want: 404, code: codeNotFound},
+		{method: "POST", path: "/get", body: `{}`, want: 422, code: codeMissingData},
+		{method: "POST", path: "/get", body: `{"id":`, want: 400, code: codeInvalidBody},
+		{method: "POST", path: "/update", body: `{"id":1,"note":"buy tea"}`, want: 200},
+		{method: "POST", path: "/update", body: `{"note":"buy tea"}`, want: 422, fields: []string{"id"}},
+		{method: "POST", path: "/update", body: `{"id":1,"name":"` + strings.Repeat("a", 101) + `"}`, want: 422, fields: []string{"name"}},
+		{method: "POST", path: "/update", body: `{"id":`, want: 400, code: codeInvalidBody},
+		{method: "POST", path: "/update", body: `{"id":99,"note":"x"}`, want: 404, code: codeNotFound},
+		{method: "POST", path: "/get-all?limit=1", want: 200},
+		{method: "POST", path: "/get-all?name=Nobody", want: 404, code: codeNotFound},
+		{method: "POST", path: "/delete", body: `{"id":3}`, want: 200},
+		{method: "POST", path: "/delete", body: `{"id":3}`, want: 404, code: codeNotFound},
+		{method: "POST", path: "/delete", body: `{}`, want: 422, code: codeMissingData},
+		{method: "POST", path: "/delete", body: `{"id":`, want: 400, code: codeInvalidBody},
+
+		{method: "DELETE", path: "/notes/2", header: map[string]string{"If-Match": `"1"`}, want: 204},
+		{method: "DELETE", path: "/notes/2", want: 404, code: codeNotFound},
+		{method: "DELETE", path: "/notes", want: 403, code: codeForbidden},
+
+		{method: "GET", path: "/metrics", want: 200},
+		{method: "GET", path: "/readyz", want: 503, code: codeNotReady, before: func() { hs.SetReady(false) }},
+		{method: "GET", path: "/readyz", header: problemJSON, want: 503, code: codeNotReady},
+	}
+
+	for _, step := range steps {
+		name := step.method + " " + step.path
+		if step.before != nil {
+			step.before()
+		}
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		if step.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range step.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		hs.srv.Handler.ServeHTTP(rec, req)
+		res := rec.Result()
+		body, _ := io.ReadAll(res.Body)
+
+		if res.StatusCode != step.want {
+			t.Errorf("%s: status %d, want %d; body %s", name, res.StatusCode, step.want, body)
+		}
+		op, tmpl, err := s.operation(step.method, req.URL.Path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		s.covered[step.method+" "+tmpl] = true
+
+		if res.StatusCode < 300 && step.body != "" {
+			for _, err := range s.checkRequest(op, req.Header.Get("Content-Type"), []byte(step.body)) {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+		for _, err := range s.checkResponse(op, res, body) {
+			t.Errorf("%s: %d: %v", name, res.StatusCode, err)
+		}
+		// У dto.Response и dto.Problem одинаковые поля code и errors.
+		var resp dto.Response
+		json.Unmarshal(body, &resp)
+		code := step.code
+		if code == "" && step.fields != nil {
+			code = codeValidationFailed
+		}
+		if resp.Code != code {
+			t.Errorf("%s: code %q, want %q", name, resp.Code, code)
+		}
+		if step.header["Accept"] == ProblemContentType {
+			var p dto.Problem
+			json.Unmarshal(body, &p)
+			if ct := res.Header.Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("%s: Content-Type %q, want %q", name, ct, ProblemContentType)
+			}
+			if p.Type != problemTypePrefix+code || p.Status != step.want || p.Instance != step.path {
+				t.Errorf("%s: problem %+v", name, p)
+			}
+		}
+		if step.fields != nil {
+			var got []string
+			for _, fe := range resp.Errors {
+				got = append(got, fe.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(step.fields, ",") {
+				t.Errorf("%s: field errors %v, want %v", name, got, step.fields)
+			}
+		}
+	}
+
+	// Каждая операция документа должна быть вызвана, иначе ее описание никто не проверяет.
+	var missing []string
+	for path, item := range s.paths() {
+		for method := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			if key := strings.ToUpper(method) + " " + path; !s.covered[key] {
+				missing = append(missing, key)
+			}
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		t.Errorf("%s is documented but not exercised by the test", key)
+	}
+}
+
+// TestOpenAPIDocumentEndpoints проверяет, что отдаваемый документ описывает ровно
+// зарегистрированные эндпоинты: у каждого пути есть обработчик, а отключенных путей нет.
+func TestOpenAPIDocumentEndpoints(t *testing.T) {
+	full := loadSpec(t, newTestServer().openapi)
+	for path := range full.paths() {
+		if route(httptest.NewRequest("GET", strings.ReplaceAll(path, "{id}", "1"), nil)) == "other" {
+			t.Errorf("%s is documented but has no handler", path)
+		}
+	}
+
+	hs := newTestServer(WithLegacyEndpoints(false), WithMetrics(false))
+	s := loadSpec(t, hs.openapi)
+	for _, path := range append(legacyPaths, metricsPath) {
+		if _, ok := s.paths()[path]; ok {
+			t.Errorf("%s is disabled but documented", path)
+		}
+	}
+	for path := range s.paths() {
+		rec := httptest.NewRecorder()
+		hs.srv.Handler.ServeHTTP(rec, httptest.NewRequest("OPTIONS", strings.ReplaceAll(path, "{id}", "1"), nil))
+		if rec.Code == http.StatusNotFound {
+			t.Errorf("%s is documented but not registered", path)
+		}
+	}
+}
+
+// TestErrorCodesDocumented проверяет, что схема ErrorCode перечисляет ровно коды errorKinds.
+func TestErrorCodesDocumented(t *testing.T) {
+	s := loadSpec(t, newTestServer().openapi)
+	schema := s.resolve(map[string]any{"$ref": "#/components/schemas/ErrorCode"})
+
+	documented := make(map[string]bool)
+	for _, code := range schema["enum"].([]any) {
+		documented[code.(string)] = true
+	}
+	codes := map[string]bool{internalError.code: true}
+	for _, k := range errorKinds {
+		codes[k.kind.code] = true
+	}
+	for code := range codes {
+		if !documented[code] {
+			t.Errorf("code %s is not documented", code)
+		}
+	}
+	for code := range documented {
+		if !codes[code] {
+			t.Errorf("code %s is documented but never returned", code)
+		}
+	}
+}
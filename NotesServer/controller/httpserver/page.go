@@ -0,0 +1,194 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"NotesServer/models/dto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Постраничная выдача списка заметок (GET /notes и /get-all).
+//
+// Параметры запроса:
+//
+//	limit=N                  - не больше N заметок на странице (0 или без параметра - defaultPageSize,
+//	                           но не больше Limits.MaxPageSize)
+//	offset=N                 - пропустить первые N подходящих заметок
+//	cursor=ID                - начать после заметки с этим id (только для сортировки по id)
+//	sort=id|name|last_name   - поле сортировки, "-" в начале - по убыванию (по умолчанию id)
+//	name=, last_name=, note= - фильтры по точному совпадению поля
+//
+// Страница ограничена всегда, поэтому список целиком за один запрос не выдается.
+// Если есть следующая страница, ее адрес возвращается в заголовке Link с rel="next".
+// При сортировке по возрастанию id заметки читаются из хранилища через Range и чтение
+// останавливается на границе страницы; для остальных сортировок подходящие заметки
+// собираются целиком. С фильтром name или last_name просматриваются только заметки,
+// найденные по индексу арендатора (см. NewTenant), если хранилище его поддерживает.
+
+// defaultMaxPageSize - наибольшее допустимое значение limit по умолчанию (см. Limits.MaxPageSize).
+const defaultMaxPageSize = 1000
+
+// defaultPageSize - размер страницы, если limit не задан.
+const defaultPageSize = 100
+
+// errBadQuery - некорректные параметры постраничной выдачи.
+var errBadQuery = errors.New("invalid query")
+
+// pageQuery - разобранные параметры постраничной выдачи.
+type pageQuery struct {
+	limit  int   // больше 0
+	offset int   // сколько подходящих заметок пропустить
+	cursor int64 // id последней заметки предыдущей страницы
+	sort   string
+	desc   bool
+	filter dto.Note // непустые поля должны совпасть
+}
+
+// parsePageQuery разбирает параметры постраничной выдачи из query; limit не может быть больше maxLimit.
+// Без limit размер страницы - defaultPageSize, но не больше maxLimit.
+func parsePageQuery(query url.Values, maxLimit int) (pageQuery, error) {
+	q := pageQuery{sort: "id"}
+
+	var err error
+	if q.limit, err = queryInt(query, "limit", maxLimit); err != nil {
+		return q, err
+	}
+	if q.limit == 0 {
+		q.limit = min(defaultPageSize, maxLimit)
+	}
+	if q.offset, err = queryInt(query, "offset", -1); err != nil {
+		return q, err
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		if q.cursor, err = strconv.ParseInt(raw, 10, 64); err != nil || q.cursor < 0 {
+			return q, fmt.Errorf("%w: cursor must be a non-negative integer", errBadQuery)
+		}
+	}
+
+	if raw := query.Get("sort"); raw != "" {
+		q.sort, q.desc = strings.TrimPrefix(raw, "-"), strings.HasPrefix(raw, "-")
+	}
+	switch q.sort {
+	case "id", "name", "last_name":
+	default:
+		return q, fmt.Errorf("%w: sort must be one of id, name, last_name", errBadQuery)
+	}
+	if q.cursor != 0 && !q.byID() {
+		return q, fmt.Errorf("%w: cursor is only supported with sort=id", errBadQuery)
+	}
+
+	q.filter.Name = query.Get("name")
+	q.filter.LastName = query.Get("last_name")
+	q.filter.Note = query.Get("note")
+	return q, nil
+}
+
+// queryInt читает неотрицательный целый параметр name; max < 0 - без верхней границы.
+func queryInt(query url.Values, name string, max int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 || (max >= 0 && n > max) {
+		if max >= 0 {
+			return 0, fmt.Errorf("%w: %s must be an integer from 0 to %d", errBadQuery, name, max)
+		}
+		return 0, fmt.Errorf("%w: %s must be a non-negative integer", errBadQuery, name)
+	}
+	return n, nil
+}
+
+// byID сообщает, что заметки выдаются по возрастанию id, то есть в порядке Range.
+func (q pageQuery) byID() bool {
+	return q.sort == "id" && !q.desc
+}
+
+// match сообщает, проходит ли заметка фильтры запроса.
+func (q pageQuery) match(note *dto.Note) bool {
+	return (q.filter.Name == "" || note.Name == q.filter.Name) &&
+		(q.filter.LastName == "" || note.LastName == q.filter.LastName) &&
+		(q.filter.Note == "" || note.Note == q.filter.Note)
+}
+
+// less сравнивает заметки по полю сортировки; при равенстве - по id.
+func (q pageQuery) less(a, b *dto.Note) bool {
+	var x, y string
+	switch q.sort {
+	case "name":
+		x, y = a.Name, b.Name
+	case "last_name":
+		x, y = a.LastName, b.LastName
+	}
+	if x == y {
+		if q.desc {
+			return a.ID > b.ID
+		}
+		return a.ID < b.ID
+	}
+	if q.desc {
+		return x > y
+	}
+	return x < y
+}
+
+// listPage возвращает страницу заметок по запросу q и сообщает, есть ли следующая.
+func (hs *HttpServer) listPage(ctx context.Context, q pageQuery) (page []*dto.Note, more bool, err error) {
+	skipped := 0
+	visit := func(_ int64, note *dto.Note) bool {
+		if !q.match(note) {
+			return true
+		}
+		if !q.byID() {
+			page = append(page, note)
+			return true
+		}
+		if skipped < q.offset {
+			skipped++
+			return true
+		}
+		page = append(page, note)
+		return len(page) <= q.limit
+	}
+	t := tenantOf(ctx)
+	if ids, ok := t.candidates(q.filter); ok {
+		err = t.rangeIDs(ctx, ids, q.cursor+1, visit)
+	} else {
+		err = t.Store.Range(ctx, q.cursor+1, visit)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !q.byID() {
+		sort.Slice(page, func(i, j int) bool { return q.less(page[i], page[j]) })
+		if q.offset >= len(page) {
+			page = nil
+		} else {
+			page = page[q.offset:]
+		}
+	}
+	if len(page) > q.limit {
+		return page[:q.limit], true, nil
+	}
+	return page, false, nil
+}
+
+// setNextLink выставляет заголовок Link со ссылкой на страницу после page.
+// Для сортировки по id следующая страница задается курсором, иначе - смещением.
+func setNextLink(w http.ResponseWriter, req *http.Request, q pageQuery, page []*dto.Note) {
+	query := req.URL.Query()
+	if q.byID() {
+		query.Set("cursor", strconv.FormatInt(page[len(page)-1].ID, 10))
+		query.Del("offset")
+	} else {
+		query.Set("offset", strconv.Itoa(q.offset+len(page)))
+	}
+	next := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+	w.Header().Set("Link", "<"+next.String()+`>; rel="next"`)
+}
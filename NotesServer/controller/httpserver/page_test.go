@@ -0,0 +1,163 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/models/dto"
+	"regexp"
+	"testing"
+)
+
+func TestParsePageQueryLimit(t *testing.T) {
+	tests := []struct {
+		query    string
+		maxLimit int
+		want     int
+		wantErr  bool
+	}{
+		{"", 1000, defaultPageSize, false},
+		{"limit=0", 1000, defaultPageSize, false},
+		{"", 10, 10, false},
+		{"limit=7", 10, 7, false},
+		{"limit=10", 10, 10, false},
+		{"limit=11", 10, 0, true},
+		{"limit=-1", 10, 0, true},
+		{"limit=x", 10, 0, true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		q, err := parsePageQuery(query, tt.maxLimit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q, max %d: error %v, want error %v", tt.query, tt.maxLimit, err, tt.wantErr)
+			continue
+		}
+		if err == nil && q.limit != tt.want {
+			t.Errorf("%q, max %d: limit %d, want %d", tt.query, tt.maxLimit, q.limit, tt.want)
+		}
+	}
+}
+
+// TestNotesListDefaultPage проверяет, что GET /notes без limit выдает ограниченную страницу
+// и ссылку на следующую, и что по ссылкам можно пройти весь список.
+func TestNotesListDefaultPage(t *testing.T) {
+	const maxPage, total = 3, 8
+	hs := newTestServer(WithLimits(Limits{MaxPageSize: maxPage}))
+	for i := 0; i < total; i++ {
+		if rec := serve(hs, "POST", notesPath, fmt.Sprintf(`{"name":"Ivan","last_name":"Petrov","note":"n%d"}`, i)); rec.Code != 201 {
+			t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+		}
+	}
+
+	next := regexp.MustCompile(`^<([^>]+)>; rel="next"$`)
+	path, seen := notesPath, 0
+	for pages := 0; path != ""; pages++ {
+		if pages > total {
+			t.Fatal("too many pages")
+		}
+		rec := serve(hs, "GET", path, "")
+		var resp struct{ Data []dto.Note }
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != 200 {
+			t.Fatalf("GET %s: status %d, body %s", path, rec.Code, rec.Body)
+		}
+		if len(resp.Data) > maxPage {
+			t.Errorf("GET %s: %d notes, want at most %d", path, len(resp.Data), maxPage)
+		}
+		for _, note := range resp.Data {
+			seen++
+			if note.ID != int64(seen) {
+				t.Errorf("GET %s: note %d, want %d", path, note.ID, seen)
+			}
+		}
+
+		path = ""
+		if m := next.FindStringSubmatch(rec.Header().Get("Link")); m != nil {
+			path = m[1]
+		}
+	}
+	if seen != total {
+		t.Errorf("%d notes listed, want %d", seen, total)
+	}
+}
+
+// listIDs проходит по ссылкам rel="next" начиная с path и возвращает id всех заметок.
+func listIDs(t *testing.T, hs *HttpServer, path string) []int64 {
+	t.Helper()
+	next := regexp.MustCompile(`^<([^>]+)>; rel="next"$`)
+	ids := []int64{}
+	for pages := 0; path != ""; pages++ {
+		if pages > 100 {
+			t.Fatal("too many pages")
+		}
+		rec := serve(hs, "GET", path, "")
+		var resp struct{ Data []dto.Note }
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != 200 {
+			t.Fatalf("GET %s: status %d, body %s", path, rec.Code, rec.Body)
+		}
+		for _, note := range resp.Data {
+			ids = append(ids, note.ID)
+		}
+		path = ""
+		if m := next.FindStringSubmatch(rec.Header().Get("Link")); m != nil {
+			path = m[1]
+		}
+	}
+	return ids
+}
+
+// TestNotesListFilterIndex проверяет, что фильтры name и last_name через индексы арендатора
+// дают те же заметки, что и полный просмотр, в том числе после изменения и удаления заметок.
+func TestNotesListFilterIndex(t *testing.T) {
+	hs := newTestServer()
+	names := []string{"Ivan", "Anna"}
+	lastNames := []string{"Petrov", "Sidorov", "Ivanov"}
+	for i := 0; i < 12; i++ {
+		body := fmt.Sprintf(`{"name":%q,"last_name":%q,"note":"n%d"}`, names[i%2], lastNames[i%3], i)
+		if rec := serve(hs, "POST", notesPath, body); rec.Code != 201 {
+			t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(hs, "DELETE", notePath(4), ""); rec.Code != 204 {
+		t.Fatalf("DELETE: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := serve(hs, "PUT", notePath(7), `{"name":"Anna","last_name":"Petrov","note":"moved"}`); rec.Code != 200 {
+		t.Fatalf("PUT: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	tnt, err := hs.tenants.Get(tenant.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tnt.byName == nil || tnt.byLastName == nil {
+		t.Fatal("tenant has no name indexes")
+	}
+
+	rec := serve(hs, "GET", notesPath+"?limit=1000", "")
+	var all struct{ Data []dto.Note }
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		match func(n dto.Note) bool
+	}{
+		{"name=Anna", func(n dto.Note) bool { return n.Name == "Anna" }},
+		{"last_name=Petrov", func(n dto.Note) bool { return n.LastName == "Petrov" }},
+		{"name=Ivan&last_name=Ivanov", func(n dto.Note) bool { return n.Name == "Ivan" && n.LastName == "Ivanov" }},
+		{"name=Anna&note=moved", func(n dto.Note) bool { return n.Name == "Anna" && n.Note == "moved" }},
+		{"name=Nobody", func(n dto.Note) bool { return false }},
+	}
+	for _, tt := range tests {
+		want := []int64{}
+		for _, n := range all.Data {
+			if tt.match(n) {
+				want = append(want, n.ID)
+			}
+		}
+		got := listIDs(t, hs, notesPath+"?limit=2&"+tt.query)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"NotesServer/models/dto"
+	"strconv"
+	"strings"
+)
+
+// Ошибки в формате RFC 7807. Клиент, указавший в Accept тип application/problem+json,
+// получает вместо dto.Response описание dto.Problem:
+//
+//	{"type":"urn:notes:problem:not_found","title":"Note not found","status":404,
+//	 "detail":"no records found","instance":"/notes/7","code":"not_found"}
+//
+// type строится из стабильного кода ошибки (см. errorKinds), title зависит только от кода,
+// detail - текст ошибки. Остальные клиенты получают код в поле code dto.Response.
+const (
+	ProblemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:notes:problem:"
+)
+
+// acceptsProblem сообщает, готов ли клиент принять ответ application/problem+json.
+func acceptsProblem(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mt, params, err := mime.ParseMediaType(part)
+			if err != nil || mt != ProblemContentType {
+				continue
+			}
+			// q=0 означает, что тип не принимается.
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// newProblem описывает ошибочный ответ resp со статусом status на запрос req.
+func newProblem(w http.ResponseWriter, req *http.Request, status int, resp *dto.Response) *dto.Problem {
+	return &dto.Problem{
+		Type:      problemTypePrefix + resp.Code,
+		Title:     errorTitle(resp.Code),
+		Status:    status,
+		Detail:    resp.Error,
+		Instance:  req.URL.RequestURI(),
+		Code:      resp.Code,
+		Errors:    resp.Errors,
+		RequestID: w.Header().Get(RequestIDHeader),
+	}
+}
+
+// writeProblem записывает ответ application/problem+json.
+func writeProblem(w http.ResponseWriter, status int, p *dto.Problem) error {
+	setHeaders(w)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"NotesServer/models/dto"
+	"NotesServer/pkg"
+	"NotesServer/pkg/auth"
+	"strings"
+)
+
+// WithPolicy включает проверку прав по ролям клиента (см. auth.Policy).
+// Требует аутентификации (WithAuth): без нее у запроса нет клиента и он получает 403.
+// Без политики доступна любая операция, кроме очистки хранилища (auth.PermClear).
+func WithPolicy(p *auth.Policy) Option {
+	return func(hs *HttpServer) {
+		hs.policy = p
+	}
+}
+
+// permission возвращает право, нужное для запроса. Для путей, которых нет в таблице,
+// возвращается false: новые эндпоинты недоступны, пока им не назначено право.
+func permission(req *http.Request) (auth.Permission, bool) {
+	path, method := req.URL.Path, req.Method
+	switch {
+	case path == "/get" || path == "/get-all" || path == searchPath:
+		return auth.PermRead, true
+	case path == "/create" || path == "/update" || path == "/delete":
+		return auth.PermWrite, true
+	case path == notesPath:
+		switch method {
+		case http.MethodGet, http.MethodHead:
+			return auth.PermRead, true
+		case http.MethodDelete:
+			return auth.PermClear, true
+		default:
+			return auth.PermWrite, true
+		}
+	case strings.HasPrefix(path, notesPath+"/"):
+		if method == http.MethodGet || method == http.MethodHead {
+			return auth.PermRead, true
+		}
+		return auth.PermWrite, true
+	default:
+		return "", false
+	}
+}
+
+// allowed сообщает, есть ли у клиента запроса право perm. Без политики доступа
+// разрешено все, кроме очистки хранилища: право auth.PermClear выдается только явно.
+func (hs *HttpServer) allowed(req *http.Request, perm auth.Permission) bool {
+	if hs.policy == nil {
+		return perm != auth.PermClear
+	}
+	principal, ok := auth.FromContext(req.Context())
+	return ok && hs.policy.Allowed(principal, perm)
+}
+
+// forbidden отвечает 403 на запрос без права на операцию.
+func forbidden(w http.ResponseWriter, req *http.Request, eW *pkg.EWrapper) {
+	err := fmt.Errorf("%w: %s %s", auth.ErrForbidden, req.Method, req.URL.Path)
+	if principal, ok := auth.FromContext(req.Context()); ok {
+		err = fmt.Errorf("%w: %s %s by %q", auth.ErrForbidden, req.Method, req.URL.Path, principal.Subject)
+	}
+	resp := &dto.Response{}
+	wrapError(resp, "Forbidden", auth.ErrForbidden)
+	eW.LogError(err, "hs.allowed(req, perm)")
+	respond(w, req, eW, http.StatusForbidden, resp)
+}
+
+// authorize пропускает к next только запросы клиентов, роли которых дают нужное право.
+// Предварительные CORS-запросы (OPTIONS) не проверяются.
+func (hs *HttpServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if perm, ok := permission(req); ok && hs.allowed(req, perm) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		forbidden(w, req, newHandlerEWrapper(req, "(hs *HttpServer) authorize()"))
+	})
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"net/http/httptest"
+	"NotesServer/pkg/auth"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile записывает content во временный файл name и возвращает его путь.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestNotesClearPermission проверяет, что DELETE /notes доступен только с правом
+// notes:clear, а без политики доступа запрещен всем, даже аутентифицированным клиентам.
+func TestNotesClearPermission(t *testing.T) {
+	keys, err := auth.LoadAPIKeys(writeFile(t, "keys.json", `{"api_keys":[
+		{"name":"alice","key":"ka","tenant":"t"},
+		{"name":"bob","key":"kb","tenant":"t"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := auth.LoadPolicy(writeFile(t, "policy.json", `{
+		"roles":{"editor":["notes:read","notes:write"],"admin":["*"]},
+		"subjects":{"alice":["admin"],"bob":["editor"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authn := &auth.Authenticator{Keys: keys}
+
+	tests := []struct {
+		name string
+		opts []Option
+		key  string
+		want int
+	}{
+		{"no auth, no policy", nil, "", 403},
+		{"auth, no policy", []Option{WithAuth(authn)}, "ka", 403},
+		{"policy, editor", []Option{WithAuth(authn), WithPolicy(policy)}, "kb", 403},
+		{"policy, admin", []Option{WithAuth(authn), WithPolicy(policy)}, "ka", 204},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := newTestServer(tt.opts...)
+			create := httptest.NewRequest("POST", notesPath, strings.NewReader(`{"name":"Ivan","last_name":"Petrov","note":"buy milk"}`))
+			create.Header.Set(auth.APIKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+			hs.srv.Handler.ServeHTTP(rec, create)
+			if rec.Code != 201 {
+				t.Fatalf("POST /notes: status %d, body %s", rec.Code, rec.Body)
+			}
+
+			req := httptest.NewRequest("DELETE", notesPath, nil)
+			req.Header.Set(auth.APIKeyHeader, tt.key)
+			rec = httptest.NewRecorder()
+			hs.srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("DELETE /notes: status %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+
+			// После запрещенной очистки заметка должна остаться.
+			if tt.want != 204 {
+				get := httptest.NewRequest("GET", notePath(1), nil)
+				get.Header.Set(auth.APIKeyHeader, tt.key)
+				rec = httptest.NewRecorder()
+				hs.srv.Handler.ServeHTTP(rec, get)
+				if rec.Code != 200 {
+					t.Errorf("GET /notes/1 after DELETE /notes: status %d", rec.Code)
+				}
+			}
+		})
+	}
+}
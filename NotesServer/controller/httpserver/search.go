@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"NotesServer/gates/storage/observed"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/search"
+)
+
+// Полнотекстовый поиск заметок:
+//
+//	GET /search?q=текст&limit=N - заметки, содержащие все слова запроса, по убыванию релевантности
+//
+// У каждого арендатора свой индекс (Tenant.Search); он поддерживается в актуальном
+// состоянии через NoteIndexer, подключенный к хранилищу как наблюдатель (observed.Observed).
+const searchPath = "/search"
+
+// defaultSearchLimit - количество результатов поиска, если limit не указан.
+const defaultSearchLimit = 20
+
+// Веса полей заметки в поиске: совпадение в имени или фамилии важнее совпадения в тексте.
+const (
+	nameWeight = 2
+	noteWeight = 1
+)
+
+// NoteIndexer - наблюдатель хранилища, индексирующий имя, фамилию и текст заметок.
+type NoteIndexer struct {
+	idx *search.Index
+}
+
+var _ observed.Observer[*dto.Note] = (*NoteIndexer)(nil)
+
+func NewNoteIndexer(idx *search.Index) *NoteIndexer {
+	return &NoteIndexer{idx: idx}
+}
+
+// Set индексирует заметку id.
+func (ni *NoteIndexer) Set(id int64, note *dto.Note) {
+	ni.idx.Set(id,
+		search.Field{Text: note.Name, Weight: nameWeight},
+		search.Field{Text: note.LastName, Weight: nameWeight},
+		search.Field{Text: note.Note, Weight: noteWeight},
+	)
+}
+
+// Remove удаляет заметку id из индекса.
+func (ni *NoteIndexer) Remove(id int64) {
+	ni.idx.Remove(id)
+}
+
+// Clear очищает индекс.
+func (ni *NoteIndexer) Clear() {
+	ni.idx.Clear()
+}
+
+func (hs *HttpServer) searchHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodOptions:
+		preflight(w, "GET, OPTIONS")
+		return
+	default:
+		methodNotAllowed(w, "GET, OPTIONS")
+		return
+	}
+
+	eW := newHandlerEWrapper(req, "(hs *HttpServer) searchHandler()")
+	status, resp := http.StatusOK, &dto.Response{}
+	defer func() { respond(w, req, eW, status, resp) }()
+
+	query := req.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		err := fmt.Errorf("%w: q is required", errBadQuery)
+		status = errorStatus(err)
+		wrapError(resp, "Invalid query", err)
+		eW.LogError(err, "query.Get(\"q\")")
+		return
+	}
+	limit, err := queryInt(query, "limit", hs.limits.MaxPageSize)
+	if err != nil {
+		status = errorStatus(err)
+		wrapError(resp, "Invalid query", err)
+		eW.LogError(err, "queryInt(query, \"limit\", hs.limits.MaxPageSize)")
+		return
+	}
+	if limit == 0 {
+		limit = min(defaultSearchLimit, hs.limits.MaxPageSize)
+	}
+
+	t := tenantOf(req.Context())
+	hits := []dto.SearchHit{}
+	for _, hit := range t.Search.Search(q, limit) {
+		note, ok, err := t.Store.GetByIndex(req.Context(), hit.ID)
+		if err != nil {
+			status = errorStatus(err)
+			wrapError(resp, "Error in finding records", err)
+			eW.LogError(err, "t.Store.GetByIndex(req.Context(), hit.ID)")
+			return
+		}
+		if !ok {
+			// Заметку удалили после поиска.
+			continue
+		}
+		hits = append(hits, dto.SearchHit{Score: hit.Score, Note: note})
+	}
+
+	hitsJSON, err := json.Marshal(hits)
+	if err != nil {
+		status = http.StatusInternalServerError
+		wrapError(resp, "Error JSON", err)
+		eW.LogError(err, "json.Marshal(hits)")
+		return
+	}
+	resp.Wrap("Success", hitsJSON, "")
+}
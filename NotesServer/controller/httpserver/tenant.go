@@ -0,0 +1,162 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/observed"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/auth"
+	"NotesServer/pkg/search"
+	"sort"
+)
+
+// Tenant - данные одного арендатора: хранилище заметок и поисковый индекс по ним.
+// Индекс должен наполняться через NewNoteIndexer, подключенный к Store (см. NewTenant).
+type Tenant struct {
+	Store  storage.Storage[*dto.Note]
+	Search *search.Index
+
+	// Индексы по имени и фамилии для фильтров списка; nil, если хранилище
+	// не поддерживает вторичные индексы (storage.Indexed), тогда список просматривается целиком.
+	byName     *storage.Index[*dto.Note, string]
+	byLastName *storage.Index[*dto.Note, string]
+}
+
+// NewTenant подключает к хранилищу st поисковый индекс, а если st поддерживает
+// вторичные индексы - еще индексы по имени и фамилии для фильтров GET /notes.
+// Все изменения заметок должны идти через Store возвращенного арендатора.
+func NewTenant(st storage.Storage[*dto.Note]) (*Tenant, error) {
+	t := &Tenant{Search: search.NewIndex()}
+	if ix, ok := st.(storage.Indexed[*dto.Note]); ok {
+		t.byName = storage.NewIndex(func(n *dto.Note) string { return n.Name })
+		t.byLastName = storage.NewIndex(func(n *dto.Note) string { return n.LastName })
+		for _, index := range []*storage.Index[*dto.Note, string]{t.byName, t.byLastName} {
+			if err := ix.AddIndex(context.Background(), index); err != nil {
+				return nil, fmt.Errorf("tenant: add index: %w", err)
+			}
+		}
+	}
+
+	observedSt, err := observed.NewObserved[*dto.Note](st, NewNoteIndexer(t.Search))
+	if err != nil {
+		return nil, err
+	}
+	t.Store = observedSt
+	return t, nil
+}
+
+// candidates возвращает по индексам арендатора id заметок (по возрастанию), среди которых
+// все подходящие под фильтр f по имени и фамилии. false - индексов или таких фильтров нет.
+func (t *Tenant) candidates(f dto.Note) (ids []int64, ok bool) {
+	filters := []struct {
+		index *storage.Index[*dto.Note, string]
+		key   string
+	}{{t.byName, f.Name}, {t.byLastName, f.LastName}}
+	for _, filter := range filters {
+		if filter.index == nil || filter.key == "" {
+			continue
+		}
+		// Берется самый короткий список; остальные фильтры проверяет pageQuery.match.
+		if found := filter.index.Lookup(filter.key); !ok || len(found) < len(ids) {
+			ids = found
+		}
+		ok = true
+	}
+	return ids, ok
+}
+
+// rangeIDs вызывает fn для заметок ids с id не меньше from, как Storage.Range.
+// Заметки, удаленные после выбора ids, пропускаются.
+func (t *Tenant) rangeIDs(ctx context.Context, ids []int64, from int64, fn func(id int64, note *dto.Note) bool) error {
+	start := sort.Search(len(ids), func(i int) bool { return ids[i] >= from })
+	for i, id := range ids[start:] {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return err
+		}
+		note, ok, err := t.Store.GetByIndex(ctx, id)
+		if err != nil {
+			return err
+		}
+		if ok && !fn(id, note) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Close сохраняет и закрывает хранилище арендатора.
+func (t *Tenant) Close() error {
+	return t.Store.Close()
+}
+
+type tenantKey struct{}
+
+// tenantOf возвращает арендатора запроса, выбранного hs.scope.
+func tenantOf(ctx context.Context) *Tenant {
+	return ctx.Value(tenantKey{}).(*Tenant)
+}
+
+// notesOf возвращает хранилище заметок арендатора запроса.
+func notesOf(ctx context.Context) storage.Storage[*dto.Note] {
+	return tenantOf(ctx).Store
+}
+
+// storeAs возвращает st или вложенное в него хранилище (см. observed.Observed.Unwrap),
+// реализующее интерфейс I.
+func storeAs[I any](st storage.Storage[*dto.Note]) (I, bool) {
+	for {
+		if s, ok := st.(I); ok {
+			return s, true
+		}
+		u, ok := st.(interface{ Unwrap() storage.Storage[*dto.Note] })
+		if !ok {
+			var zero I
+			return zero, false
+		}
+		st = u.Unwrap()
+	}
+}
+
+// owner возвращает владельца заметок, создаваемых запросом: аутентифицированного клиента.
+// Без аутентификации владелец не указывается.
+func owner(req *http.Request) string {
+	if p, ok := auth.FromContext(req.Context()); ok {
+		return p.Subject
+	}
+	return ""
+}
+
+// scope выбирает данные арендатора клиента и сохраняет их в контексте запроса,
+// поэтому обработчики видят только заметки своего арендатора.
+// Без аутентификации все запросы относятся к арендатору tenant.Default.
+func (hs *HttpServer) scope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		name := tenant.Default
+		if p, ok := auth.FromContext(req.Context()); ok {
+			name = p.Tenant
+		}
+		t, err := hs.tenants.Get(name)
+		if err != nil {
+			eW := newHandlerEWrapper(req, "(hs *HttpServer) scope()")
+			status, resp := errorStatus(err), &dto.Response{}
+			if errors.Is(err, tenant.ErrInvalidTenant) {
+				wrapError(resp, "Forbidden", err)
+			} else {
+				wrapError(resp, "Error in opening storage", err)
+			}
+			eW.LogError(err, "hs.tenants.Get(name)")
+			respond(w, req, eW, status, resp)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), tenantKey{}, t)))
+	})
+}
@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/auth"
+	"strings"
+	"testing"
+)
+
+// TestTenantIsolation проверяет, что клиент видит и удаляет только заметки своего
+// арендатора, а владельцем заметки записывается создавший ее клиент.
+func TestTenantIsolation(t *testing.T) {
+	keys, err := auth.LoadAPIKeys(writeFile(t, "keys.json", `{"api_keys":[
+		{"name":"alice","key":"ka","tenant":"a"},
+		{"name":"carol","key":"kc","tenant":"a"},
+		{"name":"bob","key":"kb","tenant":"b"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := newTestServer(WithAuth(&auth.Authenticator{Keys: keys}))
+
+	call := func(key, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(auth.APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		hs.srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+	notes := func(key string) []dto.Note {
+		t.Helper()
+		rec := call(key, "GET", notesPath, "")
+		if rec.Code != 200 {
+			t.Fatalf("GET /notes: status %d, body %s", rec.Code, rec.Body)
+		}
+		var resp dto.Response
+		var list []dto.Note
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(resp.Data, &list); err != nil {
+			t.Fatalf("%s: %v", resp.Data, err)
+		}
+		return list
+	}
+
+	if rec := call("ka", "POST", notesPath, `{"name":"Ivan","last_name":"Petrov","note":"secret of a"}`); rec.Code != 201 {
+		t.Fatalf("POST /notes as alice: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// Заметка арендатора a не видна и не удаляется клиентом арендатора b.
+	if list := notes("kb"); len(list) != 0 {
+		t.Errorf("bob lists %+v", list)
+	}
+	for _, r := range []struct{ method, path, body string }{
+		{"GET", notePath(1), ""},
+		{"PATCH", notePath(1), `{"note":"x"}`},
+		{"DELETE", notePath(1), ""},
+		{"POST", "/get", `{"id":1}`},
+		{"POST", "/delete", `{"id":1}`},
+	} {
+		if rec := call("kb", r.method, r.path, r.body); rec.Code != 404 {
+			t.Errorf("%s %s as bob: status %d, want 404; body %s", r.method, r.path, rec.Code, rec.Body)
+		}
+	}
+	if rec := call("kb", "GET", searchPath+"?q=secret", ""); strings.Contains(rec.Body.String(), "secret of a") {
+		t.Errorf("bob finds a note of tenant a: %s", rec.Body)
+	}
+
+	// У b свой счетчик id, а владелец - создавший заметку клиент.
+	if rec := call("kb", "POST", notesPath, `{"name":"Petr","last_name":"Ivanov","note":"note of b"}`); rec.Code != 201 {
+		t.Fatalf("POST /notes as bob: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := call("kc", "POST", "/create", `{"name":"Olga","last_name":"Petrova","note":"second of a"}`); rec.Code != 200 {
+		t.Fatalf("POST /create as carol: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	want := map[string][]dto.Note{
+		"ka": {
+			{ID: 1, Note: "secret of a", Owner: "alice"},
+			{ID: 2, Note: "second of a", Owner: "carol"},
+		},
+		"kb": {{ID: 1, Note: "note of b", Owner: "bob"}},
+	}
+	for key, wantNotes := range want {
+		list := notes(key)
+		if len(list) != len(wantNotes) {
+			t.Fatalf("%s lists %+v, want %+v", key, list, wantNotes)
+		}
+		for i, n := range list {
+			if w := wantNotes[i]; n.ID != w.ID || n.Note != w.Note || n.Owner != w.Owner {
+				t.Errorf("%s: note %+v, want id %d, note %q, owner %q", key, n, w.ID, w.Note, w.Owner)
+			}
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "context"
+
+// HealthChecker - хранилище, умеющее проверить свое состояние. Реализуют его хранилища,
+// зависящие от диска или внешней базы данных; хранилища в памяти всегда готовы.
+type HealthChecker interface {
+
+	// Health возвращает nil, если хранилище может обслуживать запросы, иначе причину,
+	// например недоступность файла журнала или базы данных. Проверка должна быть быстрой:
+	// ее вызывает каждый запрос /readyz.
+	Health(ctx context.Context) error
+}
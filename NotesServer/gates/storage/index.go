@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Indexer - вторичный индекс, который хранилище обновляет при каждом изменении элементов.
+//
+// Методы вызываются под блокировкой хранилища, в порядке изменений, и не должны обращаться к нему.
+type Indexer[T comparable] interface {
+	// Set сообщает, что элемент с индексом id добавлен или изменен.
+	Set(id int64, value T)
+	// Remove сообщает, что элемент с индексом id удален.
+	Remove(id int64)
+	// Clear сообщает, что хранилище очищено.
+	Clear()
+}
+
+// Indexed - хранилище, поддерживающее подключаемые вторичные индексы.
+type Indexed[T comparable] interface {
+	// AddIndex заполняет ix текущими элементами хранилища и дальше обновляет его при каждом изменении.
+	AddIndex(ctx context.Context, ix Indexer[T]) error
+}
+
+// Index - вторичный индекс по ключу, который функция key вычисляет из значения элемента,
+// например по фамилии в заметке. Для каждого ключа хранит индексы элементов по возрастанию,
+// поэтому поиск по ключу занимает O(1), а изменение элемента - O(log n) на поиск позиции.
+// Безопасен для одновременного использования.
+type Index[T comparable, K comparable] struct {
+	key  func(value T) K
+	mtx  sync.RWMutex
+	ids  map[K][]int64
+	keys map[int64]K // ключ каждого элемента, чтобы удалять его по индексу
+}
+
+var _ Indexer[int] = (*Index[int, int])(nil)
+
+// NewIndex создает пустой индекс по ключу key.
+func NewIndex[T comparable, K comparable](key func(value T) K) *Index[T, K] {
+	return &Index[T, K]{
+		key:  key,
+		ids:  make(map[K][]int64),
+		keys: make(map[int64]K),
+	}
+}
+
+// Set добавляет элемент id в индекс или переносит его под новый ключ.
+func (ix *Index[T, K]) Set(id int64, value T) {
+	key := ix.key(value)
+
+	ix.mtx.Lock()
+	defer ix.mtx.Unlock()
+
+	if old, ok := ix.keys[id]; ok {
+		if old == key {
+			return
+		}
+		ix.remove(id, old)
+	}
+	ids := ix.ids[key]
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	ix.ids[key] = ids
+	ix.keys[id] = key
+}
+
+// Remove удаляет элемент id из индекса.
+func (ix *Index[T, K]) Remove(id int64) {
+	ix.mtx.Lock()
+	defer ix.mtx.Unlock()
+
+	if key, ok := ix.keys[id]; ok {
+		ix.remove(id, key)
+	}
+}
+
+// Clear удаляет из индекса все элементы.
+func (ix *Index[T, K]) Clear() {
+	ix.mtx.Lock()
+	defer ix.mtx.Unlock()
+
+	ix.ids = make(map[K][]int64)
+	ix.keys = make(map[int64]K)
+}
+
+// Lookup возвращает индексы элементов с ключом key по возрастанию или nil, если их нет.
+func (ix *Index[T, K]) Lookup(key K) []int64 {
+	ix.mtx.RLock()
+	defer ix.mtx.RUnlock()
+
+	ids := ix.ids[key]
+	if len(ids) == 0 {
+		return nil
+	}
+	return append([]int64(nil), ids...)
+}
+
+// First возвращает наименьший индекс элемента с ключом key.
+// Если таких элементов нет, возвращается 0 и false.
+func (ix *Index[T, K]) First(key K) (int64, bool) {
+	ix.mtx.RLock()
+	defer ix.mtx.RUnlock()
+
+	ids := ix.ids[key]
+	if len(ids) == 0 {
+		return 0, false
+	}
+	return ids[0], true
+}
+
+// Len возвращает количество элементов в индексе.
+func (ix *Index[T, K]) Len() int {
+	ix.mtx.RLock()
+	defer ix.mtx.RUnlock()
+	return len(ix.keys)
+}
+
+// remove удаляет элемент id с ключом key; вызывается под блокировкой.
+func (ix *Index[T, K]) remove(id int64, key K) {
+	ids := ix.ids[key]
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	if i < len(ids) && ids[i] == id {
+		ids = append(ids[:i], ids[i+1:]...)
+	}
+	if len(ids) == 0 {
+		delete(ix.ids, key)
+	} else {
+		ix.ids[key] = ids
+	}
+	delete(ix.keys, id)
+}
+
+// Identity - ключ индекса по значению элемента целиком. Хранилища используют
+// NewIndex(Identity[T]), чтобы искать и удалять элементы по значению без полного просмотра.
+func Identity[T comparable](value T) T {
+	return value
+}
@@ -1,284 +1,367 @@
 package list
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"NotesServer/gates/storage"
-	"reflect"
-	"sync"
 )
 
-type List struct {
+// List - двусвязный список, реализующий storage.Storage[T].
+//
+// Элементы упорядочены по индексу. Для быстрого доступа к элементам хранятся
+// ноды по индексу (nodes) и индекс по значению (values), поэтому поиск, изменение
+// и удаление по индексу или значению не требуют просмотра списка.
+// Из-за индекса по значению динамический тип значений List[any] должен быть сравнимым,
+// иначе Add и операции по значению возвращают storage.ErrNotComparable. Значения
+// сравниваются оператором ==, для указателей это тождество указателя (см. storage.Storage).
+// Индексы не используются повторно: удаление элементов и Clear не уменьшают next.
+type List[T comparable] struct {
 	len       int64
-	firstNode *node
-	mtx       sync.RWMutex
+	next      int64 // индекс следующего добавляемого элемента; не уменьшается при удалении
+	firstNode *node[T]
+	lastNode  *node[T]
+	nodes     map[int64]*node[T]
+	values    *storage.Index[T, T]
+	indexes   []storage.Indexer[T] // вторичные индексы, подключенные через AddIndex
+	mtx       storage.RWMutex
 }
 
+var (
+	_ storage.Indexed[int] = (*List[int])(nil)
+	_ storage.LockStatser  = (*List[int])(nil)
+)
+
 // NewList создает новый список
-func NewList() (l *List) {
-	fmt.Println("NewList")
-	return &List{len: 0, firstNode: nil}
+func NewList[T comparable]() (l *List[T]) {
+	return &List[T]{
+		next:   1,
+		nodes:  make(map[int64]*node[T]),
+		values: storage.NewIndex(storage.Identity[T]),
+	}
 }
 
 // Len возвращает длину списка
-func (l *List) Len() (len int64) {
+func (l *List[T]) Len() int64 {
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
-	return len
+	return l.len
 }
 
 // NextIndex возвращает индекс следующего добавляемого элемента
-func (l *List) NextIndex() (index int64) {
+func (l *List[T]) NextIndex() (index int64) {
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
 
-	if l.firstNode == nil {
-		return 1
-	}
-
-	currentNode := l.firstNode
-	for ; currentNode.next != nil; currentNode = currentNode.next {
-	}
-	return currentNode.index + 1
+	return l.next
 }
 
 // Add добавляет элемент в список и возвращает его индекс
-func (l *List) Add(value interface{}) (id int64, err error) {
-	if l.len > 0 && reflect.TypeOf(l.firstNode.value).Kind() != reflect.TypeOf(value).Kind() {
-		return 0, storage.ErrMismatchType
+func (l *List[T]) Add(ctx context.Context, value T) (id int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return 0, err
 	}
 
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	newNode := &node{value: value}
-
-	if l.firstNode == nil {
-		newNode.index = 1
-		l.firstNode = newNode
-		l.len++
-		return 1, nil
-	}
-
-	l.len++
-	currentNode := l.firstNode
-	for ; currentNode.next != nil; currentNode = currentNode.next {
+	if l.firstNode != nil && !storage.SameType(l.firstNode.value, value) {
+		return 0, storage.ErrMismatchType
 	}
-	newNode.index = currentNode.index + 1
-	currentNode.next = newNode
 
-	return currentNode.index + 1, nil
+	index := l.next
+	storage.SetID(value, index)
+	newNode := &node[T]{value: value, index: index}
+	l.insertAfter(l.lastNode, newNode)
+	return newNode.index, nil
 }
 
-// RemoveByIndex добавляет элемент в список по индексу
-func (l *List) AddToIndex(value interface{}, index int64) error {
+// AddToIndex добавляет элемент в список по индексу
+//
+// Список упорядочен по индексам, поэтому элемент вставляется перед первым элементом с большим индексом.
+func (l *List[T]) AddToIndex(ctx context.Context, value T, index int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return err
+	}
+	if index < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	if index > l.len+1 {
-		return errors.New("index out of range")
+	if l.firstNode != nil && !storage.SameType(l.firstNode.value, value) {
+		return storage.ErrMismatchType
 	}
-
-	newNode := &node{value: value, index: index}
-
-	if l.firstNode == nil {
-		l.firstNode = newNode
-		l.len++
-		return nil
+	if _, exists := l.nodes[index]; exists {
+		return storage.ErrIndexExists
 	}
 
-	if index == 0 {
-		newNode.next = l.firstNode
-		l.firstNode = newNode
-	} else {
-		currentNode := l.firstNode
-		for i := int64(0); i < index-1; i++ {
-			currentNode = currentNode.next
-		}
-		newNode.next = currentNode.next
-		currentNode.next = newNode
+	// Чаще всего элемент добавляется в конец, поэтому место ищется с конца списка.
+	prevNode := l.lastNode
+	for ; prevNode != nil && prevNode.index > index; prevNode = prevNode.prev {
 	}
-
-	l.len++
+	l.insertAfter(prevNode, &node[T]{value: value, index: index})
 	return nil
-
 }
 
-// RemoveByIndex удаляет элемент из списка по индексу
-func (l *List) RemoveByIndex(id int64) {
+// Modify атомарно изменяет элемент списка по индексу
+//
+// fn вызывается под блокировкой списка и не должна обращаться к нему сама.
+func (l *List[T]) Modify(ctx context.Context, id int64, fn func(value T) (T, error)) (value T, err error) {
+	if err := ctx.Err(); err != nil {
+		return value, err
+	}
+	if id < 1 {
+		return value, storage.ErrIndexOutOfRange
+	}
+
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	if l.firstNode == nil {
-		return
+	currentNode, exists := l.nodes[id]
+	if !exists {
+		return value, storage.ErrNotFound
 	}
-	if l.firstNode.index == id {
-		l.firstNode = l.firstNode.next
-		l.len--
-		return
+	newValue, err := fn(currentNode.value)
+	if err != nil {
+		return value, err
 	}
-	prevNode := l.firstNode
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
-		if currentNode.index == id {
-			prevNode.next = currentNode.next
-			l.len--
-			return
-		}
-		prevNode = currentNode
+	if err := storage.CheckComparable(newValue); err != nil {
+		return value, err
+	}
+	if !storage.SameType(currentNode.value, newValue) {
+		return value, storage.ErrMismatchType
 	}
+	currentNode.value = newValue
+	l.index(currentNode)
+	return newValue, nil
+}
 
+// Update атомарно заменяет значение элемента по индексу
+func (l *List[T]) Update(ctx context.Context, id int64, value T) error {
+	return storage.Update[T](ctx, l, id, value)
 }
 
-// RemoveByValue удаляет элемент из списка по значению
-func (l *List) RemoveByValue(value interface{}) {
-	// if(l.firstNode.value != value){
-	// 	return 0, storage.ErrMismatchType
-	// }
+// CompareAndSwap атомарно заменяет значение элемента по индексу, если оно равно old
+func (l *List[T]) CompareAndSwap(ctx context.Context, id int64, old, new T) (bool, error) {
+	return storage.CompareAndSwap[T](ctx, l, id, old, new)
+}
+
+// RemoveByIndex удаляет элемент из списка по индексу
+func (l *List[T]) RemoveByIndex(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if id < 1 {
+		return storage.ErrIndexOutOfRange
+	}
 
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	if l.firstNode == nil {
-		return
+	currentNode, exists := l.nodes[id]
+	if !exists {
+		return storage.ErrNotFound
 	}
+	l.unlink(currentNode)
+	return nil
+}
 
-	if l.firstNode.value == value {
-		l.firstNode = l.firstNode.next
-		l.len--
-		return
+// RemoveByValue удаляет элемент из списка по значению
+func (l *List[T]) RemoveByValue(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	prevNode := l.firstNode
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
-		if currentNode.value == value {
-			prevNode.next = currentNode.next
-			l.len--
-			return
-		}
-		prevNode = currentNode
+	if err := storage.CheckComparable(value); err != nil {
+		return err
 	}
-}
 
-// RemoveAllByValue удаляет все элементы из списка по значению
-func (l *List) RemoveAllByValue(value interface{}) {
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	if l.firstNode == nil {
-		return
+	id, ok := l.values.First(value)
+	if !ok {
+		return storage.ErrNotFound
 	}
+	l.unlink(l.nodes[id])
+	return nil
+}
 
-	if l.firstNode.value == value {
-		l.firstNode = l.firstNode.next
-		l.len--
+// RemoveAllByValue удаляет все элементы из списка по значению
+func (l *List[T]) RemoveAllByValue(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	prevNode := l.firstNode
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
-		if currentNode.value == value {
-			prevNode.next = currentNode.next
-			l.len--
-			currentNode = prevNode
-		}
-		prevNode = currentNode
+	if err := storage.CheckComparable(value); err != nil {
+		return err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	ids := l.values.Lookup(value)
+	if len(ids) == 0 {
+		return storage.ErrNotFound
+	}
+	for _, id := range ids {
+		l.unlink(l.nodes[id])
 	}
+	return nil
 }
 
 // GetByIndex возвращает значение элемента по индексу.
 //
-// Если элемента с таким индексом нет, то возвращается 0 и false.
-func (l *List) GetByIndex(id int64) (value interface{}, ok bool) {
+// Если элемента с таким индексом нет, то возвращается нулевое значение T и false.
+func (l *List[T]) GetByIndex(ctx context.Context, id int64) (value T, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return value, false, err
+	}
+
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
 
-	if l.firstNode == nil {
-		return 0, false
-	}
-	if l.firstNode.index == id {
-		return l.firstNode.value, true
+	currentNode, ok := l.nodes[id]
+	if !ok {
+		return value, false, nil
 	}
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
-		if currentNode.index == id {
-			return currentNode.value, true
-		}
-	}
-	return 0, false
+	return currentNode.value, true, nil
 }
 
 // GetByValue возвращает индекс первого найденного элемента по значению.
 //
 // Если элемента с таким значением нет, то возвращается 0 и false.
-func (l *List) GetByValue(value interface{}) (id int64, ok bool) {
+func (l *List[T]) GetByValue(ctx context.Context, value T) (id int64, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return 0, false, err
+	}
+
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
 
-	if l.firstNode == nil {
-		return 0, false
-	}
-
-	if l.firstNode.value == value {
-		return l.firstNode.index, true
-	}
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
-		if currentNode.value == value {
-			return currentNode.index, true
-		}
-	}
-	return
+	id, ok = l.values.First(value)
+	return id, ok, nil
 }
 
 // GetAllByValue возвращает индексы всех найденных элементов по значению
 //
 // Если элементов с таким значением нет, то возвращается nil и false.
-func (l *List) GetAllByValue(value interface{}) (ids []int64, ok bool) {
+func (l *List[T]) GetAllByValue(ctx context.Context, value T) (ids []int64, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return nil, false, err
+	}
+
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
 
-	if l.firstNode == nil {
-		return nil, false
-	}
-
-	if l.firstNode.value == value {
-		ids = append(ids, l.firstNode.index)
-	}
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
-		if currentNode.value == value {
-			ids = append(ids, currentNode.index)
-		}
-	}
-	if len(ids) == 0 {
-		return nil, false
-	}
-	return ids, true
+	ids = l.values.Lookup(value)
+	return ids, ids != nil, nil
 }
 
 // GetAll возвращает все элементы списка
 //
 // Если список пуст, то возвращается nil и false.
-func (l *List) GetAll() (values []interface{}, ok bool) {
+func (l *List[T]) GetAll(ctx context.Context) (values []T, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
 
 	if l.firstNode == nil {
-		return nil, false
+		return nil, false, nil
 	}
-	values = append(values, l.firstNode.value)
 
-	for currentNode := l.firstNode.next; currentNode != nil; currentNode = currentNode.next {
+	values = make([]T, 0, l.len)
+	i := 0
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.next {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return nil, false, err
+		}
+		i++
 		values = append(values, currentNode.value)
 	}
-	return values, true
+	return values, true, nil
+}
+
+// Range вызывает fn для элементов списка начиная с индекса from
+func (l *List[T]) Range(ctx context.Context, from int64, fn func(id int64, value T) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	i := 0
+	for currentNode := l.seek(from); currentNode != nil; currentNode = currentNode.next {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return err
+		}
+		i++
+		if !fn(currentNode.index, currentNode.value) {
+			return nil
+		}
+	}
+	return nil
 }
 
 // Clear очищает список
-func (l *List) Clear() {
+func (l *List[T]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	l.firstNode = nil // Any memory leaks?
+	l.firstNode = nil
+	l.lastNode = nil
+	l.nodes = make(map[int64]*node[T])
 	l.len = 0
+	l.values.Clear()
+	for _, ix := range l.indexes {
+		ix.Clear()
+	}
+	return nil
+}
+
+// AddIndex подключает вторичный индекс: заполняет его текущими элементами
+// и дальше обновляет при каждом изменении
+func (l *List[T]) AddIndex(ctx context.Context, ix storage.Indexer[T]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	i := 0
+	for currentNode := l.firstNode; currentNode != nil; currentNode = currentNode.next {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return err
+		}
+		i++
+		ix.Set(currentNode.index, currentNode.value)
+	}
+	l.indexes = append(l.indexes, ix)
+	return nil
 }
 
 // Print выводит список в консоль
-func (l *List) Print() {
+func (l *List[T]) Print() {
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
 
@@ -294,3 +377,96 @@ func (l *List) Print() {
 	}
 	fmt.Printf("%v]\n", n.value)
 }
+
+// LockStats возвращает статистику блокировки списка
+func (l *List[T]) LockStats() storage.LockStats {
+	return l.mtx.Stats()
+}
+
+// Close ничего не делает: список хранится только в памяти
+func (l *List[T]) Close() error {
+	return nil
+}
+
+// seekProbes - сколько индексов подряд seek ищет в nodes, прежде чем искать ноду с конца списка.
+const seekProbes = 64
+
+// seek возвращает первую ноду с индексом не меньше from или nil; вызывается под блокировкой.
+//
+// При постраничном просмотре from следует за последним индексом предыдущей страницы,
+// и до ближайшей ноды обычно лишь несколько удаленных индексов, поэтому нода ищется
+// по nodes, а не просмотром списка с начала.
+func (l *List[T]) seek(from int64) *node[T] {
+	if l.firstNode == nil || from <= l.firstNode.index {
+		return l.firstNode
+	}
+	if from > l.lastNode.index {
+		return nil
+	}
+	for id := from; id < from+seekProbes; id++ {
+		if n, exists := l.nodes[id]; exists {
+			return n
+		}
+	}
+	// Длинный промежуток удаленных индексов: ищем с конца списка, как AddToIndex.
+	n := l.lastNode
+	for n.prev != nil && n.prev.index >= from {
+		n = n.prev
+	}
+	return n
+}
+
+// insertAfter вставляет ноду после prevNode (в начало списка, если prevNode == nil)
+// и обновляет индексы; вызывается под блокировкой
+func (l *List[T]) insertAfter(prevNode, newNode *node[T]) {
+	newNode.prev = prevNode
+	if prevNode == nil {
+		newNode.next = l.firstNode
+		l.firstNode = newNode
+	} else {
+		newNode.next = prevNode.next
+		prevNode.next = newNode
+	}
+	if newNode.next == nil {
+		l.lastNode = newNode
+	} else {
+		newNode.next.prev = newNode
+	}
+
+	l.nodes[newNode.index] = newNode
+	l.len++
+	if newNode.index >= l.next {
+		l.next = newNode.index + 1
+	}
+	l.index(newNode)
+}
+
+// unlink удаляет ноду из списка и из индексов; вызывается под блокировкой
+func (l *List[T]) unlink(n *node[T]) {
+	if n.prev == nil {
+		l.firstNode = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.next == nil {
+		l.lastNode = n.prev
+	} else {
+		n.next.prev = n.prev
+	}
+	n.prev, n.next = nil, nil
+
+	delete(l.nodes, n.index)
+	l.len--
+	l.values.Remove(n.index)
+	for _, ix := range l.indexes {
+		ix.Remove(n.index)
+	}
+}
+
+// index обновляет индексы для значения ноды; вызывается под блокировкой
+func (l *List[T]) index(n *node[T]) {
+	l.values.Set(n.index, n.value)
+	for _, ix := range l.indexes {
+		ix.Set(n.index, n.value)
+	}
+}
@@ -0,0 +1,64 @@
+package list
+
+import (
+	"context"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/storagetest"
+	"NotesServer/models/dto"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(testing.TB) storage.Storage[*dto.Note] { return NewList[*dto.Note]() })
+	storagetest.RunAny(t, func(testing.TB) storage.Storage[any] { return NewList[any]() })
+}
+
+func BenchmarkStorage(b *testing.B) {
+	storagetest.Bench(b, func(testing.TB) storage.Storage[*dto.Note] { return NewList[*dto.Note]() })
+}
+
+// TestRangeSeek проверяет, что Range начинает с первого элемента с индексом не меньше from,
+// в том числе после коротких и длинных промежутков удаленных индексов.
+func TestRangeSeek(t *testing.T) {
+	ctx := context.Background()
+	l := NewList[int]()
+	for i := 1; i <= 300; i++ {
+		if _, err := l.Add(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Удаляем 10-12 (короткий промежуток) и 100-250 (длиннее seekProbes).
+	for _, r := range [][2]int64{{10, 12}, {100, 250}} {
+		for id := r[0]; id <= r[1]; id++ {
+			if err := l.RemoveByIndex(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		from, want int64 // want - первый индекс, 0 - нет элементов
+	}{
+		{-5, 1}, {0, 1}, {1, 1}, {9, 9}, {10, 13}, {12, 13}, {99, 99},
+		{100, 251}, {180, 251}, {250, 251}, {300, 300}, {301, 0},
+	}
+	for _, tt := range tests {
+		var got []int64
+		if err := l.Range(ctx, tt.from, func(id int64, _ int) bool {
+			got = append(got, id)
+			return len(got) < 2
+		}); err != nil {
+			t.Fatal(err)
+		}
+		first := int64(0)
+		if len(got) > 0 {
+			first = got[0]
+		}
+		if first != tt.want {
+			t.Errorf("Range(from=%d) starts at %d, want %d", tt.from, first, tt.want)
+		}
+		if len(got) == 2 && got[1] <= got[0] {
+			t.Errorf("Range(from=%d): ids %v are not ascending", tt.from, got)
+		}
+	}
+}
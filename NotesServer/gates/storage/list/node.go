@@ -1,7 +1,8 @@
 package list
 
-type node struct {
+type node[T comparable] struct {
 	index int64 // уникальный индекс ноды. Необходим для того, чтобы можно было удалять ноды из списка
-	value interface{}
-	next  *node
-}
\ No newline at end of file
+	value T
+	prev  *node[T]
+	next  *node[T]
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockStats - статистика блокировки хранилища: сколько раз она захватывалась
+// и сколько всего времени операции ждали захвата.
+type LockStats struct {
+	ReadAcquired  uint64
+	WriteAcquired uint64
+	ReadWait      time.Duration
+	WriteWait     time.Duration
+}
+
+// LockStatser - хранилище, сообщающее статистику своей блокировки.
+type LockStatser interface {
+	LockStats() LockStats
+}
+
+// RWMutex - sync.RWMutex, считающий захваты и время их ожидания.
+// Нулевое значение готово к использованию.
+type RWMutex struct {
+	mu sync.RWMutex
+
+	readAcquired  atomic.Uint64
+	writeAcquired atomic.Uint64
+	readWait      atomic.Int64 // наносекунды
+	writeWait     atomic.Int64 // наносекунды
+}
+
+// Lock захватывает блокировку на запись.
+func (m *RWMutex) Lock() {
+	start := time.Now()
+	m.mu.Lock()
+	m.writeWait.Add(int64(time.Since(start)))
+	m.writeAcquired.Add(1)
+}
+
+// Unlock освобождает блокировку на запись.
+func (m *RWMutex) Unlock() {
+	m.mu.Unlock()
+}
+
+// RLock захватывает блокировку на чтение.
+func (m *RWMutex) RLock() {
+	start := time.Now()
+	m.mu.RLock()
+	m.readWait.Add(int64(time.Since(start)))
+	m.readAcquired.Add(1)
+}
+
+// RUnlock освобождает блокировку на чтение.
+func (m *RWMutex) RUnlock() {
+	m.mu.RUnlock()
+}
+
+// Stats возвращает статистику блокировки.
+func (m *RWMutex) Stats() LockStats {
+	return LockStats{
+		ReadAcquired:  m.readAcquired.Load(),
+		WriteAcquired: m.writeAcquired.Load(),
+		ReadWait:      time.Duration(m.readWait.Load()),
+		WriteWait:     time.Duration(m.writeWait.Load()),
+	}
+}
@@ -1,199 +1,382 @@
 package mp
 
 import (
+	"context"
 	"fmt"
 	"NotesServer/gates/storage"
-	"reflect"
 	"sort"
-	"sync"
 )
 
-type Map struct {
+// Map - хранилище на основе map, реализующее storage.Storage[T].
+//
+// Кроме map хранится отсортированный срез индексов keys: по нему элементы
+// перебираются в порядке возрастания индекса, а не в случайном порядке map.
+// Удаленные индексы остаются в keys, пока их не наберется больше половины,
+// чтобы удаление не сдвигало срез каждый раз.
+// Индексы не используются повторно: удаление элементов и Clear не уменьшают nextIndex.
+// Индекс по значению values позволяет искать и удалять элементы по значению
+// без просмотра всего хранилища. Поэтому динамический тип значений Map[any]
+// должен быть сравнимым (не срез, map или функция), иначе Add и операции по значению
+// возвращают storage.ErrNotComparable. Значения сравниваются оператором ==, для
+// указателей это тождество указателя (см. storage.Storage).
+type Map[T comparable] struct {
 	nextIndex int64
-	mp        map[int64]interface{}
-	mtx       sync.RWMutex
+	mp        map[int64]T
+	keys      []int64
+	stale     int // количество удаленных индексов в keys
+	values    *storage.Index[T, T]
+	indexes   []storage.Indexer[T] // вторичные индексы, подключенные через AddIndex
+	mtx       storage.RWMutex
 }
 
-func NewMap() *Map {
-	fmt.Println("NewMap")
-	return &Map{nextIndex: 1, mp: make(map[int64]interface{})}
+var (
+	_ storage.Indexed[int] = (*Map[int])(nil)
+	_ storage.LockStatser  = (*Map[int])(nil)
+)
+
+func NewMap[T comparable]() *Map[T] {
+	return &Map[T]{nextIndex: 1, mp: make(map[int64]T), values: storage.NewIndex(storage.Identity[T])}
 }
 
 // Len возвращает длину списка
-func (m *Map) Len() (l int64) {
+func (m *Map[T]) Len() (l int64) {
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 	return int64(len(m.mp))
 }
 
 // NextIndex возвращает индекс следующего добавляемого элемента
-func (m *Map) NextIndex() (index int64) {
+func (m *Map[T]) NextIndex() (index int64) {
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 
 	return m.nextIndex
 }
 
+// SetNextIndex поднимает индекс следующего добавляемого элемента до index.
+// Меньший index ничего не меняет: индексы не используются повторно.
+func (m *Map[T]) SetNextIndex(index int64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if index > m.nextIndex {
+		m.nextIndex = index
+	}
+}
+
 // Add добавляет элемент в список и возвращает его индекс
-func (m *Map) Add(value interface{}) (id int64, err error) {
-	if m.nextIndex == 1 {
-		m.mp = make(map[int64]interface{})
+func (m *Map[T]) Add(ctx context.Context, value T) (id int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
-	if m.nextIndex > 1 && reflect.TypeOf(m.mp[m.nextIndex-1]).Kind() != reflect.TypeOf(value).Kind() {
-		return 0, storage.ErrMismatchType
+	if err := storage.CheckComparable(value); err != nil {
+		return 0, err
 	}
 
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
-	m.mp[m.nextIndex] = value
+	if err := m.checkType(value); err != nil {
+		return 0, err
+	}
+
+	storage.SetID(value, m.nextIndex)
+	m.set(m.nextIndex, value)
 	m.nextIndex++
 	return m.nextIndex - 1, nil
 }
 
-func (m *Map) AddToIndex(value interface{}, index int64) error {
+// Check возвращает ошибку, которую Add вернул бы для value, не добавляя его:
+// storage.ErrNotComparable или storage.ErrMismatchType
+func (m *Map[T]) Check(value T) error {
+	if err := storage.CheckComparable(value); err != nil {
+		return err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	return m.checkType(value)
+}
+
+// AddToIndex добавляет элемент в список по индексу
+func (m *Map[T]) AddToIndex(ctx context.Context, value T, index int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return err
+	}
+	if index < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
+	if err := m.checkType(value); err != nil {
+		return err
+	}
 	if _, exists := m.mp[index]; exists {
-		return fmt.Errorf("index %d already exists", index)
+		return storage.ErrIndexExists
 	}
 
-	// if m.nextIndex > 1 && reflect.TypeOf(m.mp[m.nextIndex-1]).Kind() != reflect.TypeOf(value).Kind() {
-	// 	return storage.ErrMismatchType
-	// }
-
-	m.mp[index] = value
+	m.set(index, value)
 	if index >= m.nextIndex {
 		m.nextIndex = index + 1
 	}
 	return nil
 }
 
-// RemoveByIndex удаляет элемент из списка по индексу
-func (m *Map) RemoveByIndex(id int64) {
+// Modify атомарно изменяет элемент по индексу
+//
+// fn вызывается под блокировкой хранилища и не должна обращаться к нему сама.
+func (m *Map[T]) Modify(ctx context.Context, id int64, fn func(value T) (T, error)) (value T, err error) {
+	if err := ctx.Err(); err != nil {
+		return value, err
+	}
+	if id < 1 {
+		return value, storage.ErrIndexOutOfRange
+	}
+
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
-	delete(m.mp, id)
-	if id == m.nextIndex-1 {
-		m.nextIndex--
+	oldValue, exists := m.mp[id]
+	if !exists {
+		return value, storage.ErrNotFound
 	}
+	newValue, err := fn(oldValue)
+	if err != nil {
+		return value, err
+	}
+	if err := storage.CheckComparable(newValue); err != nil {
+		return value, err
+	}
+	if !storage.SameType(oldValue, newValue) {
+		return value, storage.ErrMismatchType
+	}
+	m.set(id, newValue)
+	return newValue, nil
 }
 
-// RemoveByValue удаляет элемент из списка по значению
-func (m *Map) RemoveByValue(value interface{}) {
+// Update атомарно заменяет значение элемента по индексу
+func (m *Map[T]) Update(ctx context.Context, id int64, value T) error {
+	return storage.Update[T](ctx, m, id, value)
+}
+
+// CompareAndSwap атомарно заменяет значение элемента по индексу, если оно равно old
+func (m *Map[T]) CompareAndSwap(ctx context.Context, id int64, old, new T) (bool, error) {
+	return storage.CompareAndSwap[T](ctx, m, id, old, new)
+}
+
+// RemoveByIndex удаляет элемент из списка по индексу
+func (m *Map[T]) RemoveByIndex(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if id < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
-	for k, v := range m.mp {
-		if v == value {
-			delete(m.mp, k)
-			if k == m.nextIndex-1 {
-				m.nextIndex--
-			}
-			return
-		}
+	if _, exists := m.mp[id]; !exists {
+		return storage.ErrNotFound
 	}
+	m.delete(id)
+	return nil
 }
 
-// RemoveAllByValue удаляет все элементы из списка по значению
-func (m *Map) RemoveAllByValue(value interface{}) {
+// RemoveByValue удаляет элемент из списка по значению
+func (m *Map[T]) RemoveByValue(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return err
+	}
+
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
-	for k, v := range m.mp {
-		if v == value {
-			delete(m.mp, k)
-		}
+	id, ok := m.values.First(value)
+	if !ok {
+		return storage.ErrNotFound
 	}
+	m.delete(id)
+	return nil
+}
 
-	keys := make([]int64, 0, len(m.mp))
-	for k := range m.mp {
-		keys = append(keys, k)
+// RemoveAllByValue удаляет все элементы из списка по значению
+func (m *Map[T]) RemoveAllByValue(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return err
 	}
 
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] < keys[j]
-	})
-	m.nextIndex = keys[len(keys)-1] + 1
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
 
+	ids := m.values.Lookup(value)
+	if len(ids) == 0 {
+		return storage.ErrNotFound
+	}
+	for _, id := range ids {
+		m.delete(id)
+	}
+	return nil
 }
 
 // GetByIndex возвращает значение элемента по индексу.
 //
-// Если элемента с таким индексом нет, то возвращается 0 и false.
-func (m *Map) GetByIndex(id int64) (value interface{}, ok bool) {
+// Если элемента с таким индексом нет, то возвращается нулевое значение T и false.
+func (m *Map[T]) GetByIndex(ctx context.Context, id int64) (value T, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return value, false, err
+	}
+
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 
 	value, ok = m.mp[id]
-	if !ok {
-		return nil, false
-	}
-	return
+	return value, ok, nil
 }
 
 // GetByValue возвращает индекс первого найденного элемента по значению.
 //
 // Если элемента с таким значением нет, то возвращается 0 и false.
-func (m *Map) GetByValue(value interface{}) (id int64, ok bool) {
+func (m *Map[T]) GetByValue(ctx context.Context, value T) (id int64, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return 0, false, err
+	}
+
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 
-	for k, v := range m.mp {
-		if v == value {
-			return k, true
-		}
-	}
-	return 0, false
+	id, ok = m.values.First(value)
+	return id, ok, nil
 }
 
 // GetAllByValue возвращает индексы всех найденных элементов по значению
 //
 // Если элементов с таким значением нет, то возвращается nil и false.
-func (m *Map) GetAllByValue(value interface{}) (ids []int64, ok bool) {
+func (m *Map[T]) GetAllByValue(ctx context.Context, value T) (ids []int64, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if err := storage.CheckComparable(value); err != nil {
+		return nil, false, err
+	}
+
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 
-	ids = make([]int64, 0)
-	for k, v := range m.mp {
-		if v == value {
-			ids = append(ids, k)
-			return ids, true
-		}
-	}
-	return nil, false
+	ids = m.values.Lookup(value)
+	return ids, ids != nil, nil
 }
 
 // GetAll возвращает все элементы списка
 //
 // Если список пуст, то возвращается nil и false.
-func (m *Map) GetAll() (values []interface{}, ok bool) {
+func (m *Map[T]) GetAll(ctx context.Context) (values []T, ok bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 
 	if len(m.mp) == 0 {
-		return nil, false
+		return nil, false, nil
 	}
 
-	for _, v := range m.mp {
-		values = append(values, v) // ?
+	values = make([]T, 0, len(m.mp))
+	for i, k := range m.keys {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return nil, false, err
+		}
+		if v, exists := m.mp[k]; exists {
+			values = append(values, v)
+		}
+	}
+	return values, true, nil
+}
+
+// Range вызывает fn для элементов списка начиная с индекса from
+func (m *Map[T]) Range(ctx context.Context, from int64, fn func(id int64, value T) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	start := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= from })
+	for i, k := range m.keys[start:] {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return err
+		}
+		v, exists := m.mp[k]
+		if !exists {
+			continue
+		}
+		if !fn(k, v) {
+			return nil
+		}
 	}
-	return values, true
+	return nil
 }
 
 // Clear очищает список
-func (m *Map) Clear() {
+func (m *Map[T]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 
-	m.mp = make(map[int64]interface{})
-	m.nextIndex = 0
+	m.mp = make(map[int64]T)
+	m.keys = nil
+	m.stale = 0
+	m.values.Clear()
+	for _, ix := range m.indexes {
+		ix.Clear()
+	}
+	return nil
+}
+
+// AddIndex подключает вторичный индекс: заполняет его текущими элементами
+// и дальше обновляет при каждом изменении
+func (m *Map[T]) AddIndex(ctx context.Context, ix storage.Indexer[T]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i, k := range m.keys {
+		if err := storage.CheckScan(ctx, i); err != nil {
+			return err
+		}
+		if v, exists := m.mp[k]; exists {
+			ix.Set(k, v)
+		}
+	}
+	m.indexes = append(m.indexes, ix)
+	return nil
 }
 
 // Print выводит список в консоль
-func (m *Map) Print() {
+func (m *Map[T]) Print() {
 	m.mtx.RLock()
 	defer m.mtx.RUnlock()
 	if len(m.mp) == 0 {
@@ -203,3 +386,77 @@ func (m *Map) Print() {
 
 	fmt.Println(m.mp)
 }
+
+// LockStats возвращает статистику блокировки карты
+func (m *Map[T]) LockStats() storage.LockStats {
+	return m.mtx.Stats()
+}
+
+// Close ничего не делает: карта хранится только в памяти
+func (m *Map[T]) Close() error {
+	return nil
+}
+
+// checkType возвращает storage.ErrMismatchType, если тип value отличается от типа элементов;
+// вызывается под блокировкой
+func (m *Map[T]) checkType(value T) error {
+	for _, v := range m.mp {
+		if !storage.SameType(v, value) {
+			return storage.ErrMismatchType
+		}
+		break
+	}
+	return nil
+}
+
+// set сохраняет значение по индексу и обновляет индексы; вызывается под блокировкой
+func (m *Map[T]) set(id int64, value T) {
+	if _, exists := m.mp[id]; !exists {
+		m.insertKey(id)
+	}
+	m.mp[id] = value
+	m.values.Set(id, value)
+	for _, ix := range m.indexes {
+		ix.Set(id, value)
+	}
+}
+
+// delete удаляет элемент по индексу и обновляет индексы; вызывается под блокировкой
+func (m *Map[T]) delete(id int64) {
+	delete(m.mp, id)
+	m.removeKey()
+	m.values.Remove(id)
+	for _, ix := range m.indexes {
+		ix.Remove(id)
+	}
+}
+
+// insertKey добавляет индекс в отсортированный срез keys
+func (m *Map[T]) insertKey(id int64) {
+	i := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= id })
+	if i < len(m.keys) && m.keys[i] == id {
+		// Индекс был удален, но еще остался в keys.
+		m.stale--
+		return
+	}
+	m.keys = append(m.keys, 0)
+	copy(m.keys[i+1:], m.keys[i:])
+	m.keys[i] = id
+}
+
+// removeKey отмечает, что один из индексов keys удален; когда удаленных становится
+// больше половины, keys сжимается
+func (m *Map[T]) removeKey() {
+	m.stale++
+	if m.stale <= len(m.keys)/2 {
+		return
+	}
+	keys := m.keys[:0]
+	for _, k := range m.keys {
+		if _, exists := m.mp[k]; exists {
+			keys = append(keys, k)
+		}
+	}
+	m.keys = keys
+	m.stale = 0
+}
@@ -0,0 +1,17 @@
+package mp
+
+import (
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/storagetest"
+	"NotesServer/models/dto"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(testing.TB) storage.Storage[*dto.Note] { return NewMap[*dto.Note]() })
+	storagetest.RunAny(t, func(testing.TB) storage.Storage[any] { return NewMap[any]() })
+}
+
+func BenchmarkStorage(b *testing.B) {
+	storagetest.Bench(b, func(testing.TB) storage.Storage[*dto.Note] { return NewMap[*dto.Note]() })
+}
@@ -0,0 +1,243 @@
+package observed
+
+import (
+	"context"
+	"fmt"
+	"NotesServer/gates/storage"
+	"sync"
+)
+
+// Observer получает уведомления об изменениях хранилища.
+//
+// Методы вызываются после успешного изменения, по одному за раз и в порядке изменений,
+// поэтому наблюдатель видит ту же последовательность состояний, что и хранилище.
+// Методы не должны обращаться к хранилищу.
+type Observer[T comparable] interface {
+	// Set сообщает, что элемент с индексом id добавлен или изменен.
+	Set(id int64, value T)
+	// Remove сообщает, что элемент с индексом id удален.
+	Remove(id int64)
+	// Clear сообщает, что хранилище очищено.
+	Clear()
+}
+
+// Observed - хранилище-обертка, сообщающее наблюдателям об изменениях вложенного хранилища.
+//
+// Изменяющие операции сериализуются, чтобы уведомления приходили в порядке изменений,
+// а удаление по значению - узнать индекс удаляемого элемента. Чтения передаются
+// вложенному хранилищу напрямую. Все изменения должны идти через обертку,
+// иначе наблюдатели о них не узнают.
+type Observed[T comparable] struct {
+	st        storage.Storage[T]
+	observers []Observer[T]
+
+	mtx sync.Mutex // сериализует изменяющие операции и уведомления
+}
+
+// NewObserved оборачивает st и сообщает наблюдателям обо всех уже сохраненных элементах,
+// чтобы они начали работу с текущим состоянием хранилища.
+func NewObserved[T comparable](st storage.Storage[T], observers ...Observer[T]) (*Observed[T], error) {
+	o := &Observed[T]{st: st, observers: observers}
+
+	err := st.Range(context.Background(), 1, func(id int64, value T) bool {
+		o.set(id, value)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("observed: load items: %w", err)
+	}
+	return o, nil
+}
+
+// Len возвращает количество элементов
+func (o *Observed[T]) Len() int64 {
+	return o.st.Len()
+}
+
+// NextIndex возвращает индекс следующего добавляемого элемента
+func (o *Observed[T]) NextIndex() int64 {
+	return o.st.NextIndex()
+}
+
+// Add добавляет элемент и сообщает о нем наблюдателям
+func (o *Observed[T]) Add(ctx context.Context, value T) (int64, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	id, err := o.st.Add(ctx, value)
+	if err != nil {
+		return 0, err
+	}
+	o.set(id, value)
+	return id, nil
+}
+
+// AddToIndex добавляет элемент по индексу и сообщает о нем наблюдателям
+func (o *Observed[T]) AddToIndex(ctx context.Context, value T, index int64) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if err := o.st.AddToIndex(ctx, value, index); err != nil {
+		return err
+	}
+	o.set(index, value)
+	return nil
+}
+
+// Modify атомарно изменяет элемент по индексу и сообщает о новом значении наблюдателям
+func (o *Observed[T]) Modify(ctx context.Context, id int64, fn func(value T) (T, error)) (T, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	value, err := o.st.Modify(ctx, id, fn)
+	if err != nil {
+		return value, err
+	}
+	o.set(id, value)
+	return value, nil
+}
+
+// Update атомарно заменяет значение элемента по индексу и сообщает о нем наблюдателям
+func (o *Observed[T]) Update(ctx context.Context, id int64, value T) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if err := o.st.Update(ctx, id, value); err != nil {
+		return err
+	}
+	o.set(id, value)
+	return nil
+}
+
+// CompareAndSwap атомарно заменяет значение элемента по индексу, если оно равно old
+func (o *Observed[T]) CompareAndSwap(ctx context.Context, id int64, old, new T) (bool, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	swapped, err := o.st.CompareAndSwap(ctx, id, old, new)
+	if swapped {
+		o.set(id, new)
+	}
+	return swapped, err
+}
+
+// RemoveByIndex удаляет элемент по индексу и сообщает об этом наблюдателям
+func (o *Observed[T]) RemoveByIndex(ctx context.Context, id int64) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if err := o.st.RemoveByIndex(ctx, id); err != nil {
+		return err
+	}
+	o.remove(id)
+	return nil
+}
+
+// RemoveByValue удаляет первый найденный элемент по значению и сообщает об этом наблюдателям
+//
+// Все изменения идут через обертку, поэтому между поиском и удалением элемент не может измениться.
+func (o *Observed[T]) RemoveByValue(ctx context.Context, value T) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	id, ok, err := o.st.GetByValue(ctx, value)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if err := o.st.RemoveByIndex(ctx, id); err != nil {
+		return err
+	}
+	o.remove(id)
+	return nil
+}
+
+// RemoveAllByValue удаляет все элементы по значению и сообщает об этом наблюдателям
+func (o *Observed[T]) RemoveAllByValue(ctx context.Context, value T) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	ids, _, err := o.st.GetAllByValue(ctx, value)
+	if err != nil {
+		return err
+	}
+	if err := o.st.RemoveAllByValue(ctx, value); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		o.remove(id)
+	}
+	return nil
+}
+
+// GetByIndex возвращает значение элемента по индексу
+func (o *Observed[T]) GetByIndex(ctx context.Context, id int64) (T, bool, error) {
+	return o.st.GetByIndex(ctx, id)
+}
+
+// GetByValue возвращает индекс первого найденного элемента по значению
+func (o *Observed[T]) GetByValue(ctx context.Context, value T) (int64, bool, error) {
+	return o.st.GetByValue(ctx, value)
+}
+
+// GetAllByValue возвращает индексы всех найденных элементов по значению
+func (o *Observed[T]) GetAllByValue(ctx context.Context, value T) ([]int64, bool, error) {
+	return o.st.GetAllByValue(ctx, value)
+}
+
+// GetAll возвращает все элементы
+func (o *Observed[T]) GetAll(ctx context.Context) ([]T, bool, error) {
+	return o.st.GetAll(ctx)
+}
+
+// Range вызывает fn для элементов начиная с индекса from
+func (o *Observed[T]) Range(ctx context.Context, from int64, fn func(id int64, value T) bool) error {
+	return o.st.Range(ctx, from, fn)
+}
+
+// Clear очищает хранилище и сообщает об этом наблюдателям
+func (o *Observed[T]) Clear(ctx context.Context) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if err := o.st.Clear(ctx); err != nil {
+		return err
+	}
+	for _, obs := range o.observers {
+		obs.Clear()
+	}
+	return nil
+}
+
+// Unwrap возвращает вложенное хранилище, например чтобы узнать, какие еще интерфейсы оно реализует.
+// Изменять его напрямую нельзя: наблюдатели об этом не узнают
+func (o *Observed[T]) Unwrap() storage.Storage[T] {
+	return o.st
+}
+
+// Print выводит содержимое хранилища в консоль
+func (o *Observed[T]) Print() {
+	o.st.Print()
+}
+
+// Close дожидается завершения изменяющих операций и закрывает вложенное хранилище
+func (o *Observed[T]) Close() error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	return o.st.Close()
+}
+
+func (o *Observed[T]) set(id int64, value T) {
+	for _, obs := range o.observers {
+		obs.Set(id, value)
+	}
+}
+
+func (o *Observed[T]) remove(id int64) {
+	for _, obs := range o.observers {
+		obs.Remove(id)
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE notes (
+    id        INTEGER PRIMARY KEY,
+    name      TEXT NOT NULL DEFAULT '',
+    last_name TEXT NOT NULL DEFAULT '',
+    note      TEXT NOT NULL DEFAULT ''
+);
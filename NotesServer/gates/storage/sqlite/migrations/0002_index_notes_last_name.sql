@@ -0,0 +1 @@
+CREATE INDEX notes_last_name ON notes (last_name);
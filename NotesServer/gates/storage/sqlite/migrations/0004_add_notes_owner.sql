@@ -0,0 +1 @@
+ALTER TABLE notes ADD COLUMN owner TEXT NOT NULL DEFAULT '';
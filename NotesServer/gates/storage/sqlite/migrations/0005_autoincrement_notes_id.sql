@@ -0,0 +1,15 @@
+-- AUTOINCREMENT не выдает id удаленных строк повторно: наибольший выданный id
+-- хранится в sqlite_sequence и не уменьшается при DELETE.
+CREATE TABLE notes_autoincrement (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      TEXT NOT NULL DEFAULT '',
+    last_name TEXT NOT NULL DEFAULT '',
+    note      TEXT NOT NULL DEFAULT '',
+    version   INTEGER NOT NULL DEFAULT 1,
+    owner     TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO notes_autoincrement (id, name, last_name, note, version, owner)
+    SELECT id, name, last_name, note, version, owner FROM notes;
+DROP TABLE notes;
+ALTER TABLE notes_autoincrement RENAME TO notes;
+CREATE INDEX notes_last_name ON notes (last_name);
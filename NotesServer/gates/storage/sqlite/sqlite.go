@@ -0,0 +1,428 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"NotesServer/gates/storage"
+	"NotesServer/models/dto"
+	"NotesServer/pkg/logging"
+
+	_ "modernc.org/sqlite" // драйвер "sqlite" на чистом Go, не требует cgo
+)
+
+// SQLite - хранилище заметок во встроенной базе данных SQLite, реализующее storage.Storage[*dto.Note].
+//
+// Поля dto.Note хранятся в отдельных столбцах таблицы notes, индекс элемента - это id строки.
+// Сравнение по значению (GetByValue, RemoveByValue, CompareAndSwap и т.д.) выполняется по всем
+// полям заметки, включая ID, Version и Owner, как оператор == для dto.Note.
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite открывает (или создает) базу данных по пути path и применяет миграции схемы.
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: open %s: %w", path, err)
+	}
+	// SQLite допускает одного писателя; одно соединение исключает SQLITE_BUSY между своими же запросами.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Len возвращает количество заметок
+func (s *SQLite) Len() (l int64) {
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM notes`).Scan(&l); err != nil {
+		slog.Error(fmt.Sprintf("sqlite: count: %v", err), slog.String(logging.KeyFunction, "(s *SQLite) Len()"))
+		return 0
+	}
+	return l
+}
+
+// NextIndex возвращает индекс следующей добавляемой заметки
+func (s *SQLite) NextIndex() (index int64) {
+	// Столбец id - AUTOINCREMENT: наибольший выданный id хранится в sqlite_sequence и не уменьшается
+	// при удалении, в отличие от MAX(id).
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) + 1 FROM sqlite_sequence WHERE name = 'notes'`).Scan(&index); err != nil {
+		slog.Error(fmt.Sprintf("sqlite: next index: %v", err), slog.String(logging.KeyFunction, "(s *SQLite) NextIndex()"))
+		return 0
+	}
+	return index
+}
+
+// errNilNote возвращается при попытке сохранить nil вместо заметки.
+var errNilNote = errors.New("sqlite: nil note")
+
+const (
+	// noteColumns - столбцы таблицы notes в порядке полей, которые читает scanNote.
+	noteColumns = "id, name, last_name, note, version, owner"
+	// matchNote - условие совпадения строки со всеми полями заметки; аргументы дает matchArgs.
+	matchNote = "id = ? AND name = ? AND last_name = ? AND note = ? AND version = ? AND owner = ?"
+	// setNote - присваивание всех полей заметки, кроме id; аргументы дает setArgs.
+	setNote = "name = ?, last_name = ?, note = ?, version = ?, owner = ?"
+)
+
+// Add добавляет заметку, присваивает ей индекс и возвращает его
+func (s *SQLite) Add(ctx context.Context, note *dto.Note) (id int64, err error) {
+	if note == nil {
+		return 0, errNilNote
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO notes (name, last_name, note, version, owner) VALUES (?, ?, ?, ?, ?)`,
+		setArgs(note)...)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: insert: %w", err)
+	}
+	if id, err = res.LastInsertId(); err != nil {
+		return 0, fmt.Errorf("sqlite: last insert id: %w", err)
+	}
+	// Индекс заметки - это id строки; сама заметка в базе не хранится, поэтому ее можно дополнить.
+	note.SetID(id)
+	return id, nil
+}
+
+// AddToIndex добавляет заметку с указанным индексом
+func (s *SQLite) AddToIndex(ctx context.Context, note *dto.Note, index int64) error {
+	if note == nil {
+		return errNilNote
+	}
+	if index < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO notes (id, name, last_name, note, version, owner) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`,
+		append([]interface{}{index}, setArgs(note)...)...)
+	if err != nil {
+		return fmt.Errorf("sqlite: insert %d: %w", index, err)
+	}
+	return expectAffected(res, storage.ErrIndexExists)
+}
+
+// Modify атомарно изменяет заметку по индексу в одной транзакции
+func (s *SQLite) Modify(ctx context.Context, id int64, fn func(note *dto.Note) (*dto.Note, error)) (*dto.Note, error) {
+	if id < 1 {
+		return nil, storage.ErrIndexOutOfRange
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	note, err := scanNote(tx.QueryRowContext(ctx, `SELECT `+noteColumns+` FROM notes WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: select %d: %w", id, err)
+	}
+
+	updated, err := fn(note)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, errNilNote
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE notes SET `+setNote+` WHERE id = ?`,
+		append(setArgs(updated), id)...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: update %d: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlite: commit: %w", err)
+	}
+	return updated, nil
+}
+
+// Update заменяет заметку по индексу
+func (s *SQLite) Update(ctx context.Context, id int64, note *dto.Note) error {
+	if note == nil {
+		return errNilNote
+	}
+	if id < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE notes SET `+setNote+` WHERE id = ?`,
+		append(setArgs(note), id)...)
+	if err != nil {
+		return fmt.Errorf("sqlite: update %d: %w", id, err)
+	}
+	return expectAffected(res, storage.ErrNotFound)
+}
+
+// CompareAndSwap заменяет заметку по индексу на new, если она равна old
+func (s *SQLite) CompareAndSwap(ctx context.Context, id int64, old, new *dto.Note) (bool, error) {
+	if old == nil || new == nil {
+		return false, errNilNote
+	}
+	if id < 1 {
+		return false, storage.ErrIndexOutOfRange
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("sqlite: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := append(append(setArgs(new), id), matchArgs(old)...)
+	res, err := tx.ExecContext(ctx, `UPDATE notes SET `+setNote+`
+		WHERE id = ? AND `+matchNote, args...)
+	if err != nil {
+		return false, fmt.Errorf("sqlite: compare and swap %d: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("sqlite: rows affected: %w", err)
+	}
+	if n == 0 {
+		// Отличаем несовпадение значения от отсутствия заметки.
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = ?)`, id).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("sqlite: select %d: %w", id, err)
+		}
+		if !exists {
+			return false, storage.ErrNotFound
+		}
+		return false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("sqlite: commit: %w", err)
+	}
+	return true, nil
+}
+
+// RemoveByIndex удаляет заметку по индексу
+func (s *SQLite) RemoveByIndex(ctx context.Context, id int64) error {
+	if id < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM notes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("sqlite: delete %d: %w", id, err)
+	}
+	return expectAffected(res, storage.ErrNotFound)
+}
+
+// RemoveByValue удаляет первую найденную заметку по значению
+func (s *SQLite) RemoveByValue(ctx context.Context, note *dto.Note) error {
+	if note == nil {
+		return storage.ErrNotFound
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM notes WHERE id = (
+		SELECT id FROM notes WHERE `+matchNote+` ORDER BY id LIMIT 1
+	)`, matchArgs(note)...)
+	if err != nil {
+		return fmt.Errorf("sqlite: delete by value: %w", err)
+	}
+	return expectAffected(res, storage.ErrNotFound)
+}
+
+// RemoveAllByValue удаляет все заметки по значению
+func (s *SQLite) RemoveAllByValue(ctx context.Context, note *dto.Note) error {
+	if note == nil {
+		return storage.ErrNotFound
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM notes WHERE `+matchNote, matchArgs(note)...)
+	if err != nil {
+		return fmt.Errorf("sqlite: delete all by value: %w", err)
+	}
+	return expectAffected(res, storage.ErrNotFound)
+}
+
+// GetByIndex возвращает заметку по индексу.
+//
+// Если заметки с таким индексом нет, то возвращается nil и false.
+func (s *SQLite) GetByIndex(ctx context.Context, id int64) (note *dto.Note, ok bool, err error) {
+	note, err = scanNote(s.db.QueryRowContext(ctx, `SELECT `+noteColumns+` FROM notes WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlite: select %d: %w", id, err)
+	}
+	return note, true, nil
+}
+
+// GetByValue возвращает индекс первой найденной заметки по значению.
+//
+// Если заметки с таким значением нет, то возвращается 0 и false.
+func (s *SQLite) GetByValue(ctx context.Context, note *dto.Note) (id int64, ok bool, err error) {
+	ids, ok, err := s.getAllByValue(ctx, note, 1)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	return ids[0], true, nil
+}
+
+// GetAllByValue возвращает индексы всех найденных заметок по значению
+//
+// Если заметок с таким значением нет, то возвращается nil и false.
+func (s *SQLite) GetAllByValue(ctx context.Context, note *dto.Note) (ids []int64, ok bool, err error) {
+	return s.getAllByValue(ctx, note, -1)
+}
+
+// GetAll возвращает все заметки в порядке индексов
+//
+// Если заметок нет, то возвращается nil и false.
+func (s *SQLite) GetAll(ctx context.Context) (values []*dto.Note, ok bool, err error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+noteColumns+` FROM notes ORDER BY id`)
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlite: select all: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, false, fmt.Errorf("sqlite: select all: %w", err)
+		}
+		values = append(values, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("sqlite: select all: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	return values, true, nil
+}
+
+// Range вызывает fn для заметок начиная с индекса from
+//
+// Строки читаются из курсора по одной, поэтому в памяти не собирается вся таблица.
+func (s *SQLite) Range(ctx context.Context, from int64, fn func(id int64, value *dto.Note) bool) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+noteColumns+` FROM notes WHERE id >= ? ORDER BY id`, from)
+	if err != nil {
+		return fmt.Errorf("sqlite: select range: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return fmt.Errorf("sqlite: select range: %w", err)
+		}
+		if !fn(note.ID, note) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("sqlite: select range: %w", err)
+	}
+	return nil
+}
+
+// Clear удаляет все заметки
+func (s *SQLite) Clear(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM notes`); err != nil {
+		return fmt.Errorf("sqlite: clear: %w", err)
+	}
+	return nil
+}
+
+// Print выводит заметки в консоль
+func (s *SQLite) Print() {
+	values, ok, err := s.GetAll(context.Background())
+	if err != nil {
+		fmt.Println("sqlite: Print:", err)
+		return
+	}
+	if !ok {
+		fmt.Println("Empty")
+		return
+	}
+	for _, v := range values {
+		fmt.Printf("%+v\n", v)
+	}
+}
+
+// Close закрывает базу данных.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// Health проверяет соединение с базой данных.
+func (s *SQLite) Health(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("sqlite: ping: %w", err)
+	}
+	return nil
+}
+
+// scanNote читает заметку из строки со столбцами noteColumns.
+func scanNote(row interface{ Scan(dest ...interface{}) error }) (*dto.Note, error) {
+	note := dto.NewNote()
+	if err := row.Scan(&note.ID, &note.Name, &note.LastName, &note.Note, &note.Version, &note.Owner); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// matchArgs возвращает аргументы условия matchNote для заметки.
+func matchArgs(note *dto.Note) []interface{} {
+	return []interface{}{note.ID, note.Name, note.LastName, note.Note, note.Version, note.Owner}
+}
+
+// setArgs возвращает аргументы присваивания setNote для заметки.
+func setArgs(note *dto.Note) []interface{} {
+	return []interface{}{note.Name, note.LastName, note.Note, note.Version, note.Owner}
+}
+
+// expectAffected возвращает errNone, если запрос не изменил ни одной строки.
+func expectAffected(res sql.Result, errNone error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite: rows affected: %w", err)
+	}
+	if n == 0 {
+		return errNone
+	}
+	return nil
+}
+
+// getAllByValue возвращает не более limit индексов заметок с указанным значением (limit < 0 - без ограничения).
+func (s *SQLite) getAllByValue(ctx context.Context, note *dto.Note, limit int) (ids []int64, ok bool, err error) {
+	if note == nil {
+		return nil, false, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM notes WHERE `+matchNote+` ORDER BY id LIMIT ?`,
+		append(matchArgs(note), limit)...)
+	if err != nil {
+		return nil, false, fmt.Errorf("sqlite: select by value: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, false, fmt.Errorf("sqlite: select by value: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("sqlite: select by value: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, false, nil
+	}
+	return ids, true, nil
+}
@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/storagetest"
+	"NotesServer/models/dto"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// oldDB создает базу со схемой версии version: применяет первые миграции так же, как migrate.
+func oldDB(t *testing.T, version int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:version] {
+		if err := applyMigration(db, m); err != nil {
+			t.Fatalf("migration %s: %v", m.name, err)
+		}
+	}
+	if version >= 1 {
+		// Заметка 3 удалена, поэтому до 0005 ее id мог бы выдаться снова.
+		for _, q := range []string{
+			`INSERT INTO notes (id, name, last_name, note) VALUES (1, 'Ivan', 'Petrov', 'buy milk')`,
+			`INSERT INTO notes (id, name, last_name, note) VALUES (2, 'Anna', 'Sidorova', 'call mom')`,
+			`INSERT INTO notes (id, name, last_name, note) VALUES (3, 'Oleg', 'Ivanov', 'deleted')`,
+			`DELETE FROM notes WHERE id = 3`,
+		} {
+			if _, err := db.Exec(q); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return path
+}
+
+func TestMigrate(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].version
+
+	for version := 0; version <= len(migrations); version++ {
+		t.Run(migrationName(migrations, version), func(t *testing.T) {
+			path := oldDB(t, version)
+			s, err := NewSQLite(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			ctx := context.Background()
+
+			var current int
+			if err := s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&current); err != nil || current != latest {
+				t.Fatalf("schema version %d, %v, want %d", current, err, latest)
+			}
+			var index string
+			if err := s.db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'notes'`).Scan(&index); err != nil || index != "notes_last_name" {
+				t.Errorf("index on notes: %q, %v", index, err)
+			}
+
+			// Старые строки получают значения новых столбцов по умолчанию.
+			got, _, err := s.GetAll(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []*dto.Note
+			if version >= 1 {
+				want = []*dto.Note{
+					{ID: 1, Name: "Ivan", LastName: "Petrov", Note: "buy milk", Version: 1},
+					{ID: 2, Name: "Anna", LastName: "Sidorova", Note: "call mom", Version: 1},
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("notes %+v, want %+v", got, want)
+			}
+
+			id, err := s.Add(ctx, &dto.Note{Name: "New", LastName: "Note", Note: "x", Version: 1, Owner: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// До 0005 id удаленной заметки 3 выдается снова, потом - уже нет.
+			wantID := int64(len(want)) + 1
+			if version >= 5 {
+				wantID = 4
+			}
+			if id != wantID {
+				t.Errorf("Add() = %d, want %d", id, wantID)
+			}
+			if err := s.RemoveByIndex(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			if next := s.NextIndex(); next != id+1 {
+				t.Errorf("NextIndex() after removing %d = %d, want %d", id, next, id+1)
+			}
+		})
+	}
+}
+
+func migrationName(migrations []migration, version int) string {
+	if version == 0 {
+		return "empty"
+	}
+	return strings.TrimSuffix(migrations[version-1].name, ".sql")
+}
+
+// TestMigrateReopen проверяет, что повторное открытие не применяет миграции снова.
+func TestMigrateReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.db")
+	for i := 0; i < 2; i++ {
+		s, err := NewSQLite(path)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if _, err := s.Add(context.Background(), &dto.Note{Name: "a", LastName: "b", Note: "c"}); err != nil {
+			t.Fatal(err)
+		}
+		var applied int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+			t.Fatal(err)
+		}
+		migrations, _ := loadMigrations()
+		if applied != len(migrations) {
+			t.Errorf("open %d: %d migrations recorded, want %d", i, applied, len(migrations))
+		}
+		s.Close()
+	}
+}
+
+// TestMigrateNewerSchema проверяет, что база с неизвестной версией схемы не открывается.
+func TestMigrateNewerSchema(t *testing.T) {
+	path := oldDB(t, 0)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version) VALUES (9999)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if s, err := NewSQLite(path); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		if s != nil {
+			s.Close()
+		}
+		t.Errorf("NewSQLite() error = %v, want newer schema error", err)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(tb testing.TB) storage.Storage[*dto.Note] {
+		s, err := NewSQLite(filepath.Join(tb.TempDir(), "notes.db"))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() { s.Close() })
+		return s
+	})
+}
@@ -1,62 +1,178 @@
 package storage
 
-import "errors"
-
-// Storage - интерфейс, представляющий обобщенное хранилище данных.
-type Storage interface {
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Storage - интерфейс, представляющий обобщенное хранилище данных с элементами типа T.
+// Если ctx отменен, операции возвращают ctx.Err(), не изменяя хранилище.
+// Значения сравниваются оператором ==: в хранилищах в памяти для указателей это тождество,
+// SQLite сравнивает все поля заметки.
+type Storage[T comparable] interface {
 
 	// Len возвращает количество элементов в хранилище.
 	Len() int64
 
-	// NextIndex возвращает индекс следующего добавляемого элемента.
+	// NextIndex возвращает индекс следующего добавляемого элемента; индексы не используются повторно.
 	NextIndex() int64
 
 	// Add добавляет элемент в хранилище и возвращает его уникальный идентификатор и возможную ошибку.
-	// value может быть любого типа.
-	// Если тип value отличается от типов уже присутствующих в хранилище элементов,
-	// возвращается ошибка ErrMismatchType. Если хранилище пусто, тип данных value становится допустимым типом для хранилища,
-	// и ошибка не возвращается.
-	Add(value interface{}) (int64, error)
+	// Если тип value отличается от типа уже присутствующих элементов, возвращается ErrMismatchType.
+	Add(ctx context.Context, value T) (int64, error)
 
 	// AddToIndex добавляет элемент в хранилище по указанному индексу.
-	AddToIndex(value interface{}, index int64) (error)
+	// Если элемент с таким индексом уже есть, возвращается ErrIndexExists.
+	AddToIndex(ctx context.Context, value T, index int64) error
+
+	// Modify атомарно заменяет элемент с индексом id результатом fn и возвращает новое значение.
+	// Если элемента нет, возвращается ErrNotFound; ошибка fn возвращается как есть.
+	Modify(ctx context.Context, id int64, fn func(value T) (T, error)) (T, error)
+
+	// Update атомарно заменяет значение элемента с индексом id на value.
+	// Если элемента нет, возвращается ErrNotFound.
+	Update(ctx context.Context, id int64, value T) error
+
+	// CompareAndSwap заменяет значение элемента с индексом id на new, если оно равно old,
+	// и сообщает, была ли сделана замена.
+	CompareAndSwap(ctx context.Context, id int64, old, new T) (bool, error)
 
 	// RemoveByIndex удаляет элемент с указанным индексом из хранилища.
-	// Если элемента с таким индексом нет, функция не делает ничего.
-	RemoveByIndex(id int64)
+	// Если элемента с таким индексом нет, возвращается ErrNotFound.
+	RemoveByIndex(ctx context.Context, id int64) error
 
 	// RemoveByValue удаляет первый найденный элемент с указанным значением из хранилища.
-	// Если элемента с таким значением нет, функция не делает ничего.
-	RemoveByValue(value interface{})
+	// Если элемента с таким значением нет, возвращается ErrNotFound.
+	RemoveByValue(ctx context.Context, value T) error
 
 	// RemoveAllByValue удаляет все элементы с указанным значением из хранилища.
-	// Если элементов с таким значением нет, функция не делает ничего.
-	RemoveAllByValue(value interface{})
+	// Если элементов с таким значением нет, возвращается ErrNotFound.
+	RemoveAllByValue(ctx context.Context, value T) error
 
 	// GetByIndex возвращает значение элемента с указанным индексом.
-	// Если элемента с таким индексом нет, возвращается nil и false.
-	GetByIndex(id int64) (interface{}, bool)
+	// Если элемента с таким индексом нет, возвращается нулевое значение T и false.
+	GetByIndex(ctx context.Context, id int64) (T, bool, error)
 
 	// GetByValue возвращает индекс первого найденного элемента с указанным значением.
 	// Если элемента с таким значением нет, возвращается 0 и false.
-	GetByValue(value interface{}) (int64, bool)
+	GetByValue(ctx context.Context, value T) (int64, bool, error)
 
 	// GetAllByValue возвращает индексы всех найденных элементов с указанным значением.
 	// Если элементов с таким значением нет, возвращается nil и false.
-	GetAllByValue(value interface{}) ([]int64, bool)
+	GetAllByValue(ctx context.Context, value T) ([]int64, bool, error)
 
 	// GetAll возвращает все элементы хранилища.
 	// Если хранилище пусто, возвращается nil и false.
-	GetAll() ([]interface{}, bool)
+	GetAll(ctx context.Context) ([]T, bool, error)
+
+	// Range вызывает fn для элементов с индексом не меньше from по возрастанию индекса, пока fn не вернет false.
+	// fn вызывается под блокировкой хранилища и не должна обращаться к нему.
+	Range(ctx context.Context, from int64, fn func(id int64, value T) bool) error
 
 	// Clear удаляет все элементы из хранилища.
-	Clear()
+	Clear(ctx context.Context) error
 
 	// Print выводит содержимое хранилища в консоль.
 	Print()
+
+	// Close сохраняет изменения и освобождает ресурсы хранилища.
+	Close() error
 }
 
 // ErrMismatchType ошибка, возвращаемая методом Add, если тип добавляемого элемента
 // не соответствует типу уже присутствующих в хранилище элементов.
+// Возможна только для хранилищ с интерфейсным типом элементов, например Storage[any].
 var ErrMismatchType = errors.New("mismatched type: the type of the provided value does not match the type of items already in the storage")
 
+// ErrNotComparable ошибка, возвращаемая хранилищами в памяти, если динамический тип значения
+// нельзя сравнить оператором == (срез, map, функция или структура с ними).
+// Возможна только для хранилищ с интерфейсным типом элементов, например Storage[any].
+var ErrNotComparable = errors.New("not comparable: the dynamic type of the provided value cannot be compared with ==")
+
+// ErrNotFound ошибка, возвращаемая операциями удаления, если элемента с указанным
+// индексом или значением нет в хранилище.
+var ErrNotFound = errors.New("not found: no item with the provided index or value in the storage")
+
+// ErrIndexOutOfRange ошибка, возвращаемая операциями с индексом, если индекс меньше 1.
+var ErrIndexOutOfRange = errors.New("index out of range: indexes start at 1")
+
+// ErrIndexExists ошибка, возвращаемая методом AddToIndex, если элемент с указанным индексом уже есть в хранилище.
+var ErrIndexExists = errors.New("index exists: the storage already has an item with the provided index")
+
+// IDSetter - значение, которое хранит собственный индекс, например *dto.Note.
+// Add присваивает индекс через SetID до того, как значение станет видно другим операциям.
+type IDSetter interface {
+	SetID(id int64)
+}
+
+// SetID присваивает value индекс id, если value реализует IDSetter.
+// Вызывается реализациями хранилища в Add до сохранения значения.
+func SetID[T any](value T, id int64) {
+	if s, ok := any(value).(IDSetter); ok {
+		s.SetID(id)
+	}
+}
+
+// Modifier - хранилище, умеющее атомарно изменять элемент по индексу.
+type Modifier[T comparable] interface {
+	Modify(ctx context.Context, id int64, fn func(value T) (T, error)) (T, error)
+}
+
+// errNotSwapped прерывает Modify в CompareAndSwap, если текущее значение не равно old.
+var errNotSwapped = errors.New("not swapped")
+
+// Update реализует Storage.Update через Modify: замена выполняется под той же блокировкой.
+func Update[T comparable](ctx context.Context, m Modifier[T], id int64, value T) error {
+	_, err := m.Modify(ctx, id, func(T) (T, error) {
+		return value, nil
+	})
+	return err
+}
+
+// CompareAndSwap реализует Storage.CompareAndSwap через Modify: сравнение и замена
+// выполняются под одной блокировкой. Значения сравниваются оператором ==.
+func CompareAndSwap[T comparable](ctx context.Context, m Modifier[T], id int64, old, new T) (bool, error) {
+	if err := CheckComparable(old); err != nil {
+		return false, err
+	}
+	_, err := m.Modify(ctx, id, func(value T) (T, error) {
+		if value != old {
+			return value, errNotSwapped
+		}
+		return new, nil
+	})
+	if errors.Is(err, errNotSwapped) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SameType сообщает, совпадают ли динамические типы a и b.
+// Для конкретного типа T всегда возвращает true; используется реализациями
+// хранилища для проверки однородности Storage[any].
+func SameType[T any](a, b T) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+// CheckComparable возвращает ErrNotComparable, если динамический тип value несравним.
+// Используется хранилищами, которые ищут элементы по значению через map,
+// чтобы вернуть ошибку вместо паники.
+func CheckComparable[T any](value T) error {
+	if t := reflect.TypeOf(value); t != nil && !t.Comparable() {
+		return ErrNotComparable
+	}
+	return nil
+}
+
+// scanCheckInterval - через сколько элементов линейный просмотр проверяет отмену контекста.
+const scanCheckInterval = 256
+
+// CheckScan возвращает ctx.Err() на каждом scanCheckInterval-м шаге i линейного просмотра
+// и nil на остальных шагах. Так отмена замечается быстро, а стоимость проверки не растет с длиной просмотра.
+func CheckScan(ctx context.Context, i int) error {
+	if i%scanCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,135 @@
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"NotesServer/gates/storage"
+	"NotesServer/models/dto"
+	"testing"
+)
+
+var sizes = []int{1_000, 10_000, 100_000}
+
+// pick возвращает i-й искомый элемент; элементы берутся вразброс по всему хранилищу.
+func pick(notes []*dto.Note, i int) *dto.Note {
+	return notes[(i*7919)%len(notes)]
+}
+
+// Bench измеряет поиск и удаление по значению и поиск по вторичному индексу
+// в сравнении с полным просмотром хранилища, которое создает newStorage.
+func Bench(b *testing.B, newStorage Factory) {
+	b.Run("GetByValue", func(b *testing.B) { benchGetByValue(b, newStorage) })
+	b.Run("ScanByValue", func(b *testing.B) { benchScanByValue(b, newStorage) })
+	b.Run("RemoveByValue", func(b *testing.B) { benchRemoveByValue(b, newStorage) })
+	b.Run("IndexLookup", func(b *testing.B) { benchIndexLookup(b, newStorage) })
+	b.Run("ScanLookup", func(b *testing.B) { benchScanLookup(b, newStorage) })
+}
+
+// benchGetByValue ищет элемент по значению через индекс по значению.
+func benchGetByValue(b *testing.B, newStorage Factory) {
+	for _, n := range sizes {
+		b.Run(fmt.Sprint("n=", n), func(b *testing.B) {
+			st := newStorage(b)
+			notes := fill(b, st, n)
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, ok, _ := st.GetByValue(ctx, pick(notes, i)); !ok {
+					b.Fatal("not found")
+				}
+			}
+		})
+	}
+}
+
+// benchScanByValue - тот же поиск полным просмотром, как было до индекса по значению.
+func benchScanByValue(b *testing.B, newStorage Factory) {
+	for _, n := range sizes {
+		b.Run(fmt.Sprint("n=", n), func(b *testing.B) {
+			st := newStorage(b)
+			notes := fill(b, st, n)
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				want, found := pick(notes, i), false
+				_ = st.Range(ctx, 1, func(_ int64, v *dto.Note) bool {
+					found = *v == *want
+					return !found
+				})
+				if !found {
+					b.Fatal("not found")
+				}
+			}
+		})
+	}
+}
+
+// benchRemoveByValue удаляет элемент по значению и возвращает его на место.
+func benchRemoveByValue(b *testing.B, newStorage Factory) {
+	for _, n := range sizes {
+		b.Run(fmt.Sprint("n=", n), func(b *testing.B) {
+			st := newStorage(b)
+			notes := fill(b, st, n)
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				v := pick(notes, i)
+				if err := st.RemoveByValue(ctx, v); err != nil {
+					b.Fatal(err)
+				}
+				if err := st.AddToIndex(ctx, v, v.ID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchIndexLookup ищет элементы по фамилии через вторичный индекс.
+func benchIndexLookup(b *testing.B, newStorage Factory) {
+	for _, n := range sizes {
+		b.Run(fmt.Sprint("n=", n), func(b *testing.B) {
+			st := newStorage(b)
+			indexed, ok := st.(storage.Indexed[*dto.Note])
+			if !ok {
+				b.Skip("storage does not support secondary indexes")
+			}
+			notes := fill(b, st, n)
+			byLastName := storage.NewIndex(func(v *dto.Note) string { return v.LastName })
+			if err := indexed.AddIndex(context.Background(), byLastName); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if ids := byLastName.Lookup(pick(notes, i).LastName); len(ids) != 100 {
+					b.Fatalf("got %d ids, want 100", len(ids))
+				}
+			}
+		})
+	}
+}
+
+// benchScanLookup - тот же поиск по фамилии полным просмотром.
+func benchScanLookup(b *testing.B, newStorage Factory) {
+	for _, n := range sizes {
+		b.Run(fmt.Sprint("n=", n), func(b *testing.B) {
+			st := newStorage(b)
+			notes := fill(b, st, n)
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var ids []int64
+				lastName := pick(notes, i).LastName
+				_ = st.Range(ctx, 1, func(id int64, v *dto.Note) bool {
+					if v.LastName == lastName {
+						ids = append(ids, id)
+					}
+					return true
+				})
+				if len(ids) != 100 {
+					b.Fatalf("got %d ids, want 100", len(ids))
+				}
+			}
+		})
+	}
+}
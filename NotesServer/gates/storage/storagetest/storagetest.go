@@ -0,0 +1,558 @@
+// Package storagetest проверяет реализации storage.Storage общим набором тестов и бенчмарков.
+//
+// Каждое хранилище вызывает Run (и RunAny, если поддерживает интерфейсный тип элементов)
+// из своих тестов со своим конструктором, поэтому все реализации проверяются одинаково.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"NotesServer/gates/storage"
+	"NotesServer/models/dto"
+	"testing"
+	"time"
+)
+
+// Factory создает пустое хранилище заметок. Закрыть его после теста должна сама Factory (tb.Cleanup).
+type Factory func(tb testing.TB) storage.Storage[*dto.Note]
+
+// AnyFactory создает пустое хранилище с интерфейсным типом элементов.
+type AnyFactory func(tb testing.TB) storage.Storage[any]
+
+// note возвращает i-ю тестовую заметку; у каждой сотой заметки из n одна фамилия.
+func note(i, n int) *dto.Note {
+	return &dto.Note{Name: fmt.Sprint("name", i), LastName: fmt.Sprint("last", i%max(n/100, 1)), Note: fmt.Sprint("note", i)}
+}
+
+// fill добавляет в st n заметок и возвращает их в том виде, в каком их вернет хранилище.
+func fill(tb testing.TB, st storage.Storage[*dto.Note], n int) []*dto.Note {
+	tb.Helper()
+	ctx := context.Background()
+	notes := make([]*dto.Note, n)
+	for i := range notes {
+		id, err := st.Add(ctx, note(i, n))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if notes[i] = get(tb, st, id); notes[i] == nil {
+			tb.Fatalf("note %d not found after Add", id)
+		}
+	}
+	return notes
+}
+
+// get возвращает заметку id или nil, если ее нет.
+func get(tb testing.TB, st storage.Storage[*dto.Note], id int64) *dto.Note {
+	tb.Helper()
+	n, ok, err := st.GetByIndex(context.Background(), id)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if !ok {
+		return nil
+	}
+	return n
+}
+
+// ids возвращает индексы всех элементов st по возрастанию.
+func ids[T comparable](tb testing.TB, st storage.Storage[T]) []int64 {
+	tb.Helper()
+	got := []int64{}
+	if err := st.Range(context.Background(), 1, func(id int64, _ T) bool {
+		got = append(got, id)
+		return true
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	return got
+}
+
+// Run проверяет хранилище заметок, которое создает newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("AddGet", func(t *testing.T) { testAddGet(t, newStorage) })
+	t.Run("AddToIndex", func(t *testing.T) { testAddToIndex(t, newStorage) })
+	t.Run("IndexErrors", func(t *testing.T) { testIndexErrors(t, newStorage) })
+	t.Run("ByValue", func(t *testing.T) { testByValue(t, newStorage) })
+	t.Run("Range", func(t *testing.T) { testRange(t, newStorage) })
+	t.Run("NextIndexNotReused", func(t *testing.T) { testNextIndexNotReused(t, newStorage) })
+	t.Run("Canceled", func(t *testing.T) { testCanceled(t, newStorage) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newStorage) })
+	t.Run("CompareAndSwap", func(t *testing.T) { testCompareAndSwap(t, newStorage) })
+	t.Run("ConcurrentIncrement", func(t *testing.T) { testConcurrentIncrement(t, newStorage) })
+}
+
+// RunAny проверяет хранилище с интерфейсным типом элементов, которое создает newStorage.
+func RunAny(t *testing.T, newStorage AnyFactory) {
+	t.Run("NotComparable", func(t *testing.T) { testNotComparable(t, newStorage) })
+	t.Run("MismatchType", func(t *testing.T) { testMismatchType(t, newStorage) })
+}
+
+func testAddGet(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	if all, ok, err := st.GetAll(ctx); err != nil || ok || all != nil {
+		t.Errorf("GetAll() on empty storage = %v, %v, %v, want nil, false", all, ok, err)
+	}
+	if got := st.NextIndex(); got != 1 {
+		t.Errorf("NextIndex() = %d, want 1", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		n := note(i, 3)
+		id, err := st.Add(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int64(i+1) || n.ID != id {
+			t.Errorf("Add() = %d, note id %d, want %d", id, n.ID, i+1)
+		}
+		got := get(t, st, id)
+		if got == nil || *got != *n {
+			t.Errorf("GetByIndex(%d) = %+v, want %+v", id, got, n)
+		}
+	}
+	if got := st.Len(); got != 3 {
+		t.Errorf("Len() = %d, want 3", got)
+	}
+	if got := st.NextIndex(); got != 4 {
+		t.Errorf("NextIndex() = %d, want 4", got)
+	}
+	if n := get(t, st, 4); n != nil {
+		t.Errorf("GetByIndex(4) = %+v, want none", n)
+	}
+	all, ok, err := st.GetAll(ctx)
+	if err != nil || !ok || len(all) != 3 {
+		t.Fatalf("GetAll() = %d notes, %v, %v, want 3", len(all), ok, err)
+	}
+	for i, n := range all {
+		if n.ID != int64(i+1) {
+			t.Errorf("GetAll()[%d].ID = %d, want %d", i, n.ID, i+1)
+		}
+	}
+}
+
+func testAddToIndex(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	fill(t, st, 2)
+
+	if err := st.AddToIndex(ctx, note(10, 10), 0); !errors.Is(err, storage.ErrIndexOutOfRange) {
+		t.Errorf("AddToIndex(0) = %v, want ErrIndexOutOfRange", err)
+	}
+	if err := st.AddToIndex(ctx, note(10, 10), 2); !errors.Is(err, storage.ErrIndexExists) {
+		t.Errorf("AddToIndex(2) = %v, want ErrIndexExists", err)
+	}
+	for _, id := range []int64{7, 5} {
+		if err := st.AddToIndex(ctx, note(int(id), 10), id); err != nil {
+			t.Fatalf("AddToIndex(%d): %v", id, err)
+		}
+	}
+	if got, want := fmt.Sprint(ids(t, st)), "[1 2 5 7]"; got != want {
+		t.Errorf("ids %s, want %s", got, want)
+	}
+	if n := get(t, st, 5); n == nil || n.Name != "name5" {
+		t.Errorf("GetByIndex(5) = %+v, want name5", n)
+	}
+	if got := st.NextIndex(); got != 8 {
+		t.Errorf("NextIndex() = %d, want 8", got)
+	}
+	if id, err := st.Add(ctx, note(8, 10)); err != nil || id != 8 {
+		t.Errorf("Add() = %d, %v, want 8", id, err)
+	}
+}
+
+func testIndexErrors(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	fill(t, st, 1)
+
+	keep := func(n *dto.Note) (*dto.Note, error) { return n, nil }
+	tests := []struct {
+		name string
+		op   func(id int64) error
+	}{
+		{"RemoveByIndex", func(id int64) error { return st.RemoveByIndex(ctx, id) }},
+		{"Modify", func(id int64) error { _, err := st.Modify(ctx, id, keep); return err }},
+	}
+	for _, tt := range tests {
+		if err := tt.op(0); !errors.Is(err, storage.ErrIndexOutOfRange) {
+			t.Errorf("%s(0) = %v, want ErrIndexOutOfRange", tt.name, err)
+		}
+		if err := tt.op(2); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s(2) = %v, want ErrNotFound", tt.name, err)
+		}
+	}
+	if st.Len() != 1 || get(t, st, 1) == nil {
+		t.Errorf("storage changed: len %d", st.Len())
+	}
+}
+
+// testByValue ищет и удаляет заметки по значению, полученному из хранилища: оно равно
+// элементу и в хранилищах в памяти (тот же указатель), и в SQLite (те же поля).
+func testByValue(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	notes := fill(t, st, 3)
+	missing := &dto.Note{ID: 9, Name: "nobody"}
+
+	if id, ok, err := st.GetByValue(ctx, notes[1]); err != nil || !ok || id != 2 {
+		t.Errorf("GetByValue() = %d, %v, %v, want 2", id, ok, err)
+	}
+	if ids, ok, err := st.GetAllByValue(ctx, notes[2]); err != nil || !ok || fmt.Sprint(ids) != "[3]" {
+		t.Errorf("GetAllByValue() = %v, %v, %v, want [3]", ids, ok, err)
+	}
+	if id, ok, err := st.GetByValue(ctx, missing); err != nil || ok {
+		t.Errorf("GetByValue(missing) = %d, %v, %v, want not found", id, ok, err)
+	}
+	if ids, ok, err := st.GetAllByValue(ctx, missing); err != nil || ok || ids != nil {
+		t.Errorf("GetAllByValue(missing) = %v, %v, %v, want nil", ids, ok, err)
+	}
+
+	if err := st.RemoveByValue(ctx, notes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RemoveAllByValue(ctx, notes[2]); err != nil {
+		t.Fatal(err)
+	}
+	for name, err := range map[string]error{
+		"RemoveByValue":    st.RemoveByValue(ctx, missing),
+		"RemoveAllByValue": st.RemoveAllByValue(ctx, missing),
+	} {
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s(missing) = %v, want ErrNotFound", name, err)
+		}
+	}
+	if got := fmt.Sprint(ids(t, st)); got != "[2]" {
+		t.Errorf("ids %s, want [2]", got)
+	}
+}
+
+func testRange(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	fill(t, st, 6)
+	for _, id := range []int64{2, 4} {
+		if err := st.RemoveByIndex(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		from  int64
+		limit int
+		want  string
+	}{
+		{0, 10, "[1 3 5 6]"},
+		{1, 2, "[1 3]"},
+		{2, 10, "[3 5 6]"},
+		{4, 1, "[5]"},
+		{6, 10, "[6]"},
+		{7, 10, "[]"},
+	}
+	for _, tt := range tests {
+		got := []int64{}
+		err := st.Range(ctx, tt.from, func(id int64, n *dto.Note) bool {
+			if n.ID != id {
+				t.Errorf("Range: note %d under id %d", n.ID, id)
+			}
+			got = append(got, id)
+			return len(got) < tt.limit
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("Range(from=%d, limit %d) = %v, want %s", tt.from, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// testNextIndexNotReused проверяет, что индексы удаленных элементов не выдаются повторно.
+func testNextIndexNotReused(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		remove func(st storage.Storage[*dto.Note], last *dto.Note) error
+	}{
+		{"RemoveByIndex", func(st storage.Storage[*dto.Note], last *dto.Note) error { return st.RemoveByIndex(ctx, last.ID) }},
+		{"RemoveByValue", func(st storage.Storage[*dto.Note], last *dto.Note) error { return st.RemoveByValue(ctx, last) }},
+		{"RemoveAllByValue", func(st storage.Storage[*dto.Note], last *dto.Note) error { return st.RemoveAllByValue(ctx, last) }},
+		{"Clear", func(st storage.Storage[*dto.Note], _ *dto.Note) error { return st.Clear(ctx) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newStorage(t)
+			notes := fill(t, st, 3)
+			last := notes[len(notes)-1]
+			if err := tt.remove(st, last); err != nil {
+				t.Fatal(err)
+			}
+			if got := st.NextIndex(); got != last.ID+1 {
+				t.Errorf("NextIndex() = %d, want %d", got, last.ID+1)
+			}
+			if got, err := st.Add(ctx, note(9, 10)); err != nil || got != last.ID+1 {
+				t.Errorf("Add() = %d, %v, want %d", got, err, last.ID+1)
+			}
+		})
+	}
+}
+
+func testUpdate(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	fill(t, st, 2)
+
+	if err := st.Update(ctx, 1, &dto.Note{Name: "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := get(t, st, 1); n == nil || n.Name != "updated" {
+		t.Errorf("GetByIndex(1) = %+v, want updated", n)
+	}
+	if err := st.Update(ctx, 3, &dto.Note{Name: "missing"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Update(3) = %v, want ErrNotFound", err)
+	}
+	if err := st.Update(ctx, 0, &dto.Note{Name: "zero"}); !errors.Is(err, storage.ErrIndexOutOfRange) {
+		t.Errorf("Update(0) = %v, want ErrIndexOutOfRange", err)
+	}
+	if got := fmt.Sprint(ids(t, st)); got != "[1 2]" {
+		t.Errorf("ids %s, want [1 2]", got)
+	}
+}
+
+func testCompareAndSwap(t *testing.T, newStorage Factory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	fill(t, st, 2)
+
+	old := get(t, st, 1)
+	swapped, err := st.CompareAndSwap(ctx, 1, old, &dto.Note{Name: "first"})
+	if err != nil || !swapped {
+		t.Fatalf("CompareAndSwap(current) = %v, %v, want true", swapped, err)
+	}
+	if n := get(t, st, 1); n == nil || n.Name != "first" {
+		t.Errorf("GetByIndex(1) = %+v, want first", n)
+	}
+
+	// old больше не равен текущему значению: замены нет.
+	swapped, err = st.CompareAndSwap(ctx, 1, old, &dto.Note{Name: "second"})
+	if err != nil || swapped {
+		t.Errorf("CompareAndSwap(stale) = %v, %v, want false", swapped, err)
+	}
+	if n := get(t, st, 1); n == nil || n.Name != "first" {
+		t.Errorf("GetByIndex(1) = %+v after a mismatch, want first", n)
+	}
+	// Значение другого элемента тоже не подходит.
+	if swapped, err := st.CompareAndSwap(ctx, 1, get(t, st, 2), &dto.Note{Name: "third"}); err != nil || swapped {
+		t.Errorf("CompareAndSwap(other note) = %v, %v, want false", swapped, err)
+	}
+
+	if _, err := st.CompareAndSwap(ctx, 3, old, &dto.Note{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("CompareAndSwap(3) = %v, want ErrNotFound", err)
+	}
+	if _, err := st.CompareAndSwap(ctx, 0, old, &dto.Note{}); !errors.Is(err, storage.ErrIndexOutOfRange) {
+		t.Errorf("CompareAndSwap(0) = %v, want ErrIndexOutOfRange", err)
+	}
+}
+
+// testConcurrentIncrement увеличивает Version одной заметки из нескольких горутин через Modify
+// и через цикл чтение - CompareAndSwap и проверяет, что ни одно увеличение не потеряно.
+func testConcurrentIncrement(t *testing.T, newStorage Factory) {
+	const workers, increments = 8, 25
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		increment func(st storage.Storage[*dto.Note]) error
+	}{
+		{"Modify", func(st storage.Storage[*dto.Note]) error {
+			_, err := st.Modify(ctx, 1, func(n *dto.Note) (*dto.Note, error) {
+				next := *n
+				next.Version++
+				return &next, nil
+			})
+			return err
+		}},
+		{"CompareAndSwap", func(st storage.Storage[*dto.Note]) error {
+			for {
+				cur, ok, err := st.GetByIndex(ctx, 1)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return storage.ErrNotFound
+				}
+				next := *cur
+				next.Version++
+				swapped, err := st.CompareAndSwap(ctx, 1, cur, &next)
+				if err != nil || swapped {
+					return err
+				}
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newStorage(t)
+			fill(t, st, 1)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, workers)
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < increments; i++ {
+						if err := tt.increment(st); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+			if n := get(t, st, 1); n == nil || n.Version != workers*increments {
+				t.Errorf("GetByIndex(1) = %+v, want version %d", n, workers*increments)
+			}
+		})
+	}
+}
+
+// testCanceled проверяет, что с отмененным или истекшим контекстом операции возвращают
+// ctx.Err() и не меняют хранилище.
+func testCanceled(t *testing.T, newStorage Factory) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	for name, ctx := range map[string]context.Context{"canceled": canceled, "expired": expired} {
+		t.Run(name, func(t *testing.T) {
+			st := newStorage(t)
+			notes := fill(t, st, 3)
+			before := snapshot(t, st)
+			called := false
+			change := func(n *dto.Note) (*dto.Note, error) {
+				called = true
+				return &dto.Note{Name: "changed"}, nil
+			}
+
+			ops := []struct {
+				name string
+				op   func() error
+			}{
+				{"Add", func() error { _, err := st.Add(ctx, note(9, 10)); return err }},
+				{"AddToIndex", func() error { return st.AddToIndex(ctx, note(9, 10), 9) }},
+				{"Modify", func() error { _, err := st.Modify(ctx, 1, change); return err }},
+				{"Update", func() error { return st.Update(ctx, 1, note(9, 10)) }},
+				{"CompareAndSwap", func() error { _, err := st.CompareAndSwap(ctx, 1, notes[0], note(9, 10)); return err }},
+				{"RemoveByIndex", func() error { return st.RemoveByIndex(ctx, 1) }},
+				{"RemoveByValue", func() error { return st.RemoveByValue(ctx, notes[0]) }},
+				{"RemoveAllByValue", func() error { return st.RemoveAllByValue(ctx, notes[0]) }},
+				{"Clear", func() error { return st.Clear(ctx) }},
+				{"GetByIndex", func() error { _, _, err := st.GetByIndex(ctx, 1); return err }},
+				{"GetByValue", func() error { _, _, err := st.GetByValue(ctx, notes[0]); return err }},
+				{"GetAllByValue", func() error { _, _, err := st.GetAllByValue(ctx, notes[0]); return err }},
+				{"GetAll", func() error { _, _, err := st.GetAll(ctx); return err }},
+				{"Range", func() error {
+					return st.Range(ctx, 1, func(int64, *dto.Note) bool { called = true; return true })
+				}},
+			}
+			for _, op := range ops {
+				if err := op.op(); !errors.Is(err, ctx.Err()) {
+					t.Errorf("%s: %v, want %v", op.name, err, ctx.Err())
+				}
+			}
+			if called {
+				t.Error("callback called with a done context")
+			}
+			if after := snapshot(t, st); after != before {
+				t.Errorf("storage changed:\n%s\nwant:\n%s", after, before)
+			}
+		})
+	}
+}
+
+// snapshot описывает содержимое st и NextIndex строкой, чтобы сравнить состояние до и после операций.
+func snapshot(t *testing.T, st storage.Storage[*dto.Note]) string {
+	t.Helper()
+	state := fmt.Sprintf("len %d, next %d", st.Len(), st.NextIndex())
+	if err := st.Range(context.Background(), 1, func(id int64, n *dto.Note) bool {
+		state += fmt.Sprintf("\n%d: %+v", id, *n)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// testNotComparable проверяет, что несравнимые значения в хранилище any дают
+// ErrNotComparable, а не панику в индексе по значению.
+func testNotComparable(t *testing.T, newStorage AnyFactory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	if _, err := st.Add(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := []int{1}
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{"Add", func() error { _, err := st.Add(ctx, bad); return err }},
+		{"AddToIndex", func() error { return st.AddToIndex(ctx, bad, 10) }},
+		{"Update", func() error { return st.Update(ctx, 1, bad) }},
+		{"CompareAndSwap", func() error { _, err := st.CompareAndSwap(ctx, 1, bad, 2); return err }},
+		{"RemoveByValue", func() error { return st.RemoveByValue(ctx, bad) }},
+		{"RemoveAllByValue", func() error { return st.RemoveAllByValue(ctx, map[string]int{}) }},
+		{"GetByValue", func() error { _, _, err := st.GetByValue(ctx, struct{ s []int }{}); return err }},
+		{"GetAllByValue", func() error { _, _, err := st.GetAllByValue(ctx, func() {}); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, storage.ErrNotComparable) {
+				t.Errorf("got %v, want ErrNotComparable", err)
+			}
+		})
+	}
+	if got, ok, _ := st.GetByIndex(ctx, 1); !ok || got != 1 || st.Len() != 1 {
+		t.Errorf("storage changed: %v, %v, len %d", got, ok, st.Len())
+	}
+}
+
+// testMismatchType проверяет, что хранилище any принимает значения только одного типа.
+func testMismatchType(t *testing.T, newStorage AnyFactory) {
+	ctx := context.Background()
+	st := newStorage(t)
+	if _, err := st.Add(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{"Add", func() error { _, err := st.Add(ctx, "one"); return err }},
+		{"AddToIndex", func() error { return st.AddToIndex(ctx, "one", 5) }},
+		{"Update", func() error { return st.Update(ctx, 1, "one") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, storage.ErrMismatchType) {
+				t.Errorf("got %v, want ErrMismatchType", err)
+			}
+		})
+	}
+	if got := fmt.Sprint(ids(t, st)); got != "[1]" {
+		t.Errorf("ids %s, want [1]", got)
+	}
+	if got, _, _ := st.GetByIndex(ctx, 1); got != 1 {
+		t.Errorf("GetByIndex(1) = %v, want 1", got)
+	}
+}
@@ -0,0 +1,142 @@
+// Package tenant разделяет данные между арендаторами: у каждого арендатора
+// свой экземпляр хранилища, который создается при первом обращении.
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Default - арендатор запросов без аутентификации.
+const Default = "default"
+
+// ErrInvalidTenant - имя арендатора не подходит для имени каталога с его данными.
+var ErrInvalidTenant = errors.New("tenant: invalid tenant name")
+
+// validName - допустимые имена арендаторов: безопасны как имя каталога и не могут быть "." или "..".
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Valid сообщает, допустимо ли имя арендатора.
+func Valid(name string) bool {
+	return validName.MatchString(name)
+}
+
+// Registry хранит по одному значению S (например, хранилищу) на арендатора.
+// Значение создается функцией open при первом обращении и дальше переиспользуется.
+// Безопасен для одновременного использования.
+type Registry[S any] struct {
+	open  func(tenant string) (S, error)
+	mtx   sync.Mutex
+	items map[string]*entry[S]
+}
+
+// entry - значение арендатора. ready закрывается, когда open завершилась;
+// после этого item и err не меняются.
+type entry[S any] struct {
+	ready chan struct{}
+	item  S
+	err   error
+}
+
+func NewRegistry[S any](open func(tenant string) (S, error)) *Registry[S] {
+	return &Registry[S]{open: open, items: make(map[string]*entry[S])}
+}
+
+// Get возвращает значение арендатора tenant, при необходимости создавая его.
+// Если имя недопустимо, возвращается ErrInvalidTenant.
+//
+// open выполняется без блокировки реестра, поэтому медленное открытие хранилища
+// одного арендатора не задерживает остальных. Одновременные вызовы Get для одного
+// арендатора ждут общего результата; после ошибки следующий Get повторяет open.
+func (r *Registry[S]) Get(tenant string) (S, error) {
+	var zero S
+	if !Valid(tenant) {
+		return zero, ErrInvalidTenant
+	}
+
+	r.mtx.Lock()
+	e, ok := r.items[tenant]
+	if !ok {
+		e = &entry[S]{ready: make(chan struct{})}
+		r.items[tenant] = e
+	}
+	r.mtx.Unlock()
+
+	if ok {
+		<-e.ready
+	} else {
+		r.create(tenant, e)
+	}
+	if e.err != nil {
+		return zero, e.err
+	}
+	return e.item, nil
+}
+
+// create открывает значение арендатора tenant в e и убирает e из реестра при ошибке.
+func (r *Registry[S]) create(tenant string, e *entry[S]) {
+	defer close(e.ready)
+	item, err := r.open(tenant)
+	if err != nil {
+		e.err = fmt.Errorf("tenant: open %q: %w", tenant, err)
+		r.mtx.Lock()
+		if r.items[tenant] == e {
+			delete(r.items, tenant)
+		}
+		r.mtx.Unlock()
+		return
+	}
+	e.item = item
+}
+
+// Each вызывает fn для каждого уже созданного значения в порядке имен арендаторов.
+// Значения, которые еще открываются, пропускаются.
+// fn вызывается без блокировки реестра и может обращаться к нему.
+func (r *Registry[S]) Each(fn func(tenant string, item S)) {
+	r.mtx.Lock()
+	names := make([]string, 0, len(r.items))
+	items := make(map[string]S, len(r.items))
+	for name, e := range r.items {
+		select {
+		case <-e.ready:
+			if e.err == nil {
+				names = append(names, name)
+				items[name] = e.item
+			}
+		default:
+		}
+	}
+	r.mtx.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, items[name])
+	}
+}
+
+// Close закрывает все значения, реализующие io.Closer, и возвращает их ошибки.
+// Значения, которые еще открываются, закрываются после открытия.
+func (r *Registry[S]) Close() error {
+	r.mtx.Lock()
+	entries := r.items
+	r.items = make(map[string]*entry[S])
+	r.mtx.Unlock()
+
+	var errs []error
+	for name, e := range entries {
+		<-e.ready
+		if e.err != nil {
+			continue
+		}
+		if c, ok := any(e.item).(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("tenant: close %q: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
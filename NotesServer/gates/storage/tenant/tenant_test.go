@@ -0,0 +1,145 @@
+package tenant
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type closer struct {
+	name   string
+	closed atomic.Bool
+}
+
+func (c *closer) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestValid(t *testing.T) {
+	for name, want := range map[string]bool{
+		"acme": true, "team-a.1_b": true, Default: true,
+		"": false, ".": false, "..": false, "-a": false, "a/b": false, "a b": false,
+	} {
+		if got := Valid(name); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestGetOnce проверяет, что одновременные Get одного арендатора открывают его один раз.
+func TestGetOnce(t *testing.T) {
+	var opened atomic.Int32
+	r := NewRegistry(func(tenant string) (*closer, error) {
+		opened.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return &closer{name: tenant}, nil
+	})
+
+	var wg sync.WaitGroup
+	items := make([]*closer, 16)
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item, err := r.Get("acme")
+			if err != nil {
+				t.Error(err)
+			}
+			items[i] = item
+		}(i)
+	}
+	wg.Wait()
+
+	if n := opened.Load(); n != 1 {
+		t.Errorf("open called %d times, want 1", n)
+	}
+	for _, item := range items {
+		if item != items[0] {
+			t.Fatal("Get returned different values for one tenant")
+		}
+	}
+}
+
+// TestGetSlowOpen проверяет, что медленное открытие одного арендатора
+// не блокирует Get и Each для остальных.
+func TestGetSlowOpen(t *testing.T) {
+	release := make(chan struct{})
+	r := NewRegistry(func(tenant string) (*closer, error) {
+		if tenant == "slow" {
+			<-release
+		}
+		return &closer{name: tenant}, nil
+	})
+
+	slow := make(chan error)
+	go func() {
+		_, err := r.Get("slow")
+		slow <- err
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := r.Get("fast"); err != nil {
+			t.Error(err)
+		}
+		var names []string
+		r.Each(func(name string, _ *closer) { names = append(names, name) })
+		if len(names) != 1 || names[0] != "fast" {
+			t.Errorf("Each: %v, want [fast]", names)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get(fast) is blocked by Get(slow)")
+	}
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGetError проверяет, что ошибка open не запоминается и следующий Get повторяет open.
+func TestGetError(t *testing.T) {
+	errOpen := errors.New("disk full")
+	fail := true
+	r := NewRegistry(func(tenant string) (*closer, error) {
+		if fail {
+			return nil, errOpen
+		}
+		return &closer{name: tenant}, nil
+	})
+
+	if _, err := r.Get("acme"); !errors.Is(err, errOpen) {
+		t.Fatalf("Get: %v, want %v", err, errOpen)
+	}
+	r.Each(func(name string, _ *closer) { t.Errorf("Each after failed open: %s", name) })
+
+	fail = false
+	if item, err := r.Get("acme"); err != nil || item.name != "acme" {
+		t.Fatalf("Get after failure: %v, %v", item, err)
+	}
+	if _, err := r.Get("../acme"); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("Get(../acme): %v, want %v", err, ErrInvalidTenant)
+	}
+}
+
+func TestClose(t *testing.T) {
+	r := NewRegistry(func(tenant string) (*closer, error) {
+		return &closer{name: tenant}, nil
+	})
+	a, _ := r.Get("a")
+	b, _ := r.Get("b")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.closed.Load() || !b.closed.Load() {
+		t.Error("Close did not close all values")
+	}
+	r.Each(func(name string, _ *closer) { t.Errorf("Each after Close: %s", name) })
+}
@@ -0,0 +1,36 @@
+package wal
+
+import "encoding/json"
+
+const (
+	opAdd    = "add"
+	opSet    = "set"
+	opRemove = "remove"
+	opClear  = "clear"
+)
+
+// record - одна запись журнала предзаписи.
+// Журнал хранит физические операции: добавление значения по индексу,
+// замену значения по индексу, удаление по индексу и очистку. Операции по значению (RemoveByValue, RemoveAllByValue)
+// перед записью разворачиваются в удаления по индексу, поэтому повторное
+// применение журнала не зависит от сравнения значений.
+type record struct {
+	Seq   int64           `json:"seq"`
+	Op    string          `json:"op"`
+	ID    int64           `json:"id,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// snapshot - сжатый снимок состояния хранилища.
+// Seq - номер последней записи журнала, вошедшей в снимок.
+// NextIndex сохраняется, чтобы после перезапуска не выдавать индексы удаленных элементов.
+type snapshot struct {
+	Seq       int64   `json:"seq"`
+	NextIndex int64   `json:"next_index,omitempty"`
+	Items     []entry `json:"items"`
+}
+
+type entry struct {
+	ID    int64           `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
@@ -0,0 +1,566 @@
+package wal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/mp"
+	"NotesServer/pkg/logging"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	logFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	// DefaultSnapshotEvery - количество записей журнала, после которого делается снимок.
+	DefaultSnapshotEvery = 1000
+)
+
+// WAL - хранилище, сохраняющее данные на диск.
+//
+// Данные хранятся в памяти в mp.Map[T], а каждая изменяющая операция
+// дописывается в журнал предзаписи (wal.log) в каталоге dir.
+// При запуске состояние восстанавливается из последнего снимка (snapshot.json)
+// и журнала. Каждые snapshotEvery записей журнал сжимается в новый снимок.
+// Значения сохраняются в формате JSON, поэтому T должен кодироваться encoding/json.
+type WAL[T comparable] struct {
+	dir           string
+	snapshotEvery int64
+
+	mem *mp.Map[T]
+
+	mtx     sync.Mutex // сериализует изменяющие операции и запись в журнал
+	log     *os.File
+	seq     int64 // номер последней записанной операции
+	records int64 // количество записей в журнале после последнего снимка
+
+	closed atomic.Bool // Close уже вызван; читается Health без блокировки mtx
+}
+
+// NewWAL открывает хранилище в каталоге dir и восстанавливает его состояние.
+//
+// Если snapshotEvery <= 0, используется DefaultSnapshotEvery.
+func NewWAL[T comparable](dir string, snapshotEvery int64) (*WAL[T], error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("wal: create data dir: %w", err)
+	}
+
+	w := &WAL[T]{
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+		mem:           mp.NewMap[T](),
+	}
+	if err := w.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	valid, err := w.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(w.path(logFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("wal: open log: %w", err)
+	}
+	// Отрезаем оборванную последнюю запись, чтобы новые записи не склеились с ней.
+	if err := log.Truncate(valid); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("wal: truncate log: %w", err)
+	}
+	w.log = log
+	return w, nil
+}
+
+// Len возвращает количество элементов в хранилище
+func (w *WAL[T]) Len() int64 {
+	return w.mem.Len()
+}
+
+// NextIndex возвращает индекс следующего добавляемого элемента
+func (w *WAL[T]) NextIndex() int64 {
+	return w.mem.NextIndex()
+}
+
+// Add записывает операцию в журнал, добавляет элемент в хранилище и возвращает индекс элемента.
+//
+// Элемент появляется в памяти только после записи в журнал, поэтому читатели не видят
+// элементов, которые не были сохранены.
+func (w *WAL[T]) Add(ctx context.Context, value T) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	// Изменяющие операции сериализованы w.mtx, поэтому проверка и индекс остаются верными до записи в память.
+	if err := w.mem.Check(value); err != nil {
+		return 0, err
+	}
+	id := w.mem.NextIndex()
+	storage.SetID(value, id)
+	if err := w.appendValue(opAdd, id, value); err != nil {
+		return 0, err
+	}
+	if err := w.mem.AddToIndex(context.Background(), value, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// AddToIndex записывает операцию в журнал и добавляет элемент в хранилище по индексу
+func (w *WAL[T]) AddToIndex(ctx context.Context, value T, index int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if index < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	if err := w.mem.Check(value); err != nil {
+		return err
+	}
+	_, exists, err := w.mem.GetByIndex(ctx, index)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return storage.ErrIndexExists
+	}
+	if err := w.appendValue(opAdd, index, value); err != nil {
+		return err
+	}
+	return w.mem.AddToIndex(context.Background(), value, index)
+}
+
+// Modify атомарно изменяет элемент по индексу: записывает новое значение в журнал
+// и только после этого применяет его к памяти.
+//
+// fn вызывается под блокировкой изменяющих операций и не должна обращаться к хранилищу сама.
+func (w *WAL[T]) Modify(ctx context.Context, id int64, fn func(value T) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if id < 1 {
+		return zero, storage.ErrIndexOutOfRange
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	oldValue, exists, err := w.mem.GetByIndex(ctx, id)
+	if err != nil {
+		return zero, err
+	}
+	if !exists {
+		return zero, storage.ErrNotFound
+	}
+	newValue, err := fn(oldValue)
+	if err != nil {
+		return zero, err
+	}
+	if err := w.mem.Check(newValue); err != nil {
+		return zero, err
+	}
+	if err := w.appendValue(opSet, id, newValue); err != nil {
+		return zero, err
+	}
+	return w.mem.Modify(context.Background(), id, func(T) (T, error) { return newValue, nil })
+}
+
+// Update атомарно заменяет значение элемента по индексу
+func (w *WAL[T]) Update(ctx context.Context, id int64, value T) error {
+	return storage.Update[T](ctx, w, id, value)
+}
+
+// CompareAndSwap атомарно заменяет значение элемента по индексу, если оно равно old
+func (w *WAL[T]) CompareAndSwap(ctx context.Context, id int64, old, new T) (bool, error) {
+	return storage.CompareAndSwap[T](ctx, w, id, old, new)
+}
+
+// RemoveByIndex удаляет элемент из хранилища по индексу.
+//
+// Если запись в журнал не удалась, элемент не удаляется и возвращается ошибка записи.
+func (w *WAL[T]) RemoveByIndex(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	if id < 1 {
+		return storage.ErrIndexOutOfRange
+	}
+	_, ok, err := w.mem.GetByIndex(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotFound
+	}
+	return w.remove(id)
+}
+
+// RemoveByValue удаляет первый найденный элемент с указанным значением
+func (w *WAL[T]) RemoveByValue(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	id, ok, err := w.mem.GetByValue(ctx, value)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotFound
+	}
+	return w.remove(id)
+}
+
+// RemoveAllByValue удаляет все элементы с указанным значением
+func (w *WAL[T]) RemoveAllByValue(ctx context.Context, value T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	ids, ok, err := w.mem.GetAllByValue(ctx, value)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrNotFound
+	}
+	for _, id := range ids {
+		if err := w.remove(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByIndex возвращает значение элемента по индексу.
+//
+// Если элемента с таким индексом нет, то возвращается нулевое значение T и false.
+func (w *WAL[T]) GetByIndex(ctx context.Context, id int64) (T, bool, error) {
+	return w.mem.GetByIndex(ctx, id)
+}
+
+// GetByValue возвращает индекс первого найденного элемента по значению.
+//
+// Если элемента с таким значением нет, то возвращается 0 и false.
+func (w *WAL[T]) GetByValue(ctx context.Context, value T) (int64, bool, error) {
+	return w.mem.GetByValue(ctx, value)
+}
+
+// GetAllByValue возвращает индексы всех найденных элементов по значению
+//
+// Если элементов с таким значением нет, то возвращается nil и false.
+func (w *WAL[T]) GetAllByValue(ctx context.Context, value T) ([]int64, bool, error) {
+	return w.mem.GetAllByValue(ctx, value)
+}
+
+// GetAll возвращает все элементы хранилища
+//
+// Если хранилище пусто, то возвращается nil и false.
+func (w *WAL[T]) GetAll(ctx context.Context) ([]T, bool, error) {
+	return w.mem.GetAll(ctx)
+}
+
+// Range вызывает fn для элементов хранилища начиная с индекса from
+func (w *WAL[T]) Range(ctx context.Context, from int64, fn func(id int64, value T) bool) error {
+	return w.mem.Range(ctx, from, fn)
+}
+
+// AddIndex подключает вторичный индекс к данным в памяти
+func (w *WAL[T]) AddIndex(ctx context.Context, ix storage.Indexer[T]) error {
+	return w.mem.AddIndex(ctx, ix)
+}
+
+// LockStats возвращает статистику блокировки данных в памяти. Изменяющие операции
+// перед ней ждут еще и записи в журнал, это время сюда не входит
+func (w *WAL[T]) LockStats() storage.LockStats {
+	return w.mem.LockStats()
+}
+
+// Clear очищает хранилище и записывает операцию в журнал
+func (w *WAL[T]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	defer w.compact()
+
+	if err := w.append(record{Op: opClear}); err != nil {
+		return err
+	}
+	return w.mem.Clear(context.Background())
+}
+
+// Print выводит хранилище в консоль
+func (w *WAL[T]) Print() {
+	w.mem.Print()
+}
+
+// Snapshot сохраняет сжатый снимок текущего состояния и очищает журнал.
+func (w *WAL[T]) Snapshot() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.snapshot()
+}
+
+// Close сохраняет снимок и закрывает файл журнала.
+func (w *WAL[T]) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.closed.Store(true)
+	err := w.snapshot()
+	if errClose := w.log.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// Health сообщает, что хранилище закрыто или его каталог недоступен.
+// Блокировку не захватывает, чтобы проверка не ждала записи в журнал.
+func (w *WAL[T]) Health(ctx context.Context) error {
+	if w.closed.Load() {
+		return fmt.Errorf("wal: %w", os.ErrClosed)
+	}
+	if _, err := os.Stat(w.path(logFileName)); err != nil {
+		return fmt.Errorf("wal: log unavailable: %w", err)
+	}
+	return nil
+}
+
+// remove записывает удаление в журнал и удаляет элемент из памяти.
+// Запись в журнал уже сделана, поэтому удаление из памяти не отменяется через ctx.
+func (w *WAL[T]) remove(id int64) error {
+	if err := w.append(record{Op: opRemove, ID: id}); err != nil {
+		return err
+	}
+	return w.mem.RemoveByIndex(context.Background(), id)
+}
+
+func (w *WAL[T]) appendValue(op string, id int64, value T) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("wal: encode value: %w", err)
+	}
+	return w.append(record{Op: op, ID: id, Value: raw})
+}
+
+// append дописывает запись в журнал и сбрасывает её на диск.
+func (w *WAL[T]) append(rec record) error {
+	rec.Seq = w.seq + 1
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("wal: encode record: %w", err)
+	}
+	if _, err := w.log.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("wal: write log: %w", err)
+	}
+	if err := w.log.Sync(); err != nil {
+		return fmt.Errorf("wal: sync log: %w", err)
+	}
+	w.seq = rec.Seq
+	w.records++
+	return nil
+}
+
+// compact делает снимок, если в журнале накопилось snapshotEvery записей.
+// Вызывается после применения операции к памяти, под w.mtx.
+func (w *WAL[T]) compact() {
+	if w.records < w.snapshotEvery {
+		return
+	}
+	// Журнал уже записан, поэтому ошибка снимка не приводит к потере данных: операция
+	// считается выполненной, а снимок повторяется после следующей записи.
+	if err := w.snapshot(); err != nil {
+		slog.Error(err.Error(), slog.String(logging.KeyFunction, "(w *WAL[T]) compact()"), slog.String("dir", w.dir))
+	}
+}
+
+// snapshot записывает снимок во временный файл, атомарно подменяет им
+// предыдущий снимок и обрезает журнал. Записи журнала, попавшие в снимок,
+// при восстановлении пропускаются по номеру Seq, поэтому сбой между
+// переименованием и обрезкой не приводит к повторному применению операций.
+func (w *WAL[T]) snapshot() error {
+	snap := snapshot{Seq: w.seq, NextIndex: w.mem.NextIndex(), Items: make([]entry, 0, w.mem.Len())}
+	var encodeErr error
+	err := w.mem.Range(context.Background(), 1, func(id int64, value T) bool {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			encodeErr = fmt.Errorf("wal: encode value: %w", err)
+			return false
+		}
+		snap.Items = append(snap.Items, entry{ID: id, Value: raw})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	tmp, err := os.CreateTemp(w.dir, snapshotFileName+".*")
+	if err != nil {
+		return fmt.Errorf("wal: create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("wal: write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("wal: sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("wal: close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.path(snapshotFileName)); err != nil {
+		return fmt.Errorf("wal: rename snapshot: %w", err)
+	}
+
+	if err := w.log.Truncate(0); err != nil {
+		return fmt.Errorf("wal: truncate log: %w", err)
+	}
+	w.records = 0
+	return nil
+}
+
+// loadSnapshot загружает последний снимок, если он есть.
+func (w *WAL[T]) loadSnapshot() error {
+	f, err := os.Open(w.path(snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("wal: open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil {
+		return fmt.Errorf("wal: decode snapshot: %w", err)
+	}
+	for _, e := range snap.Items {
+		if err := w.apply(record{Op: opAdd, ID: e.ID, Value: e.Value}); err != nil {
+			return err
+		}
+	}
+	w.mem.SetNextIndex(snap.NextIndex)
+	w.seq = snap.Seq
+	return nil
+}
+
+// replay применяет записи журнала, сделанные после последнего снимка,
+// и возвращает длину корректной части журнала.
+// Неполная последняя строка (обрыв записи при сбое) игнорируется.
+func (w *WAL[T]) replay() (valid int64, err error) {
+	f, err := os.Open(w.path(logFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("wal: open log: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("wal: read log: %w", err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return 0, fmt.Errorf("wal: decode record after seq %d: %w", w.seq, err)
+		}
+		valid += int64(len(line))
+		if rec.Seq <= w.seq {
+			continue
+		}
+		if err := w.apply(rec); err != nil {
+			return 0, err
+		}
+		w.seq = rec.Seq
+		w.records++
+	}
+}
+
+// apply применяет запись к хранилищу в памяти.
+func (w *WAL[T]) apply(rec record) error {
+	ctx := context.Background()
+	switch rec.Op {
+	case opAdd:
+		var value T
+		if err := json.Unmarshal(rec.Value, &value); err != nil {
+			return fmt.Errorf("wal: decode value %d: %w", rec.ID, err)
+		}
+		if err := w.mem.AddToIndex(ctx, value, rec.ID); err != nil {
+			return fmt.Errorf("wal: restore %d: %w", rec.ID, err)
+		}
+	case opSet:
+		var value T
+		if err := json.Unmarshal(rec.Value, &value); err != nil {
+			return fmt.Errorf("wal: decode value %d: %w", rec.ID, err)
+		}
+		if _, err := w.mem.Modify(ctx, rec.ID, func(T) (T, error) { return value, nil }); err != nil {
+			return fmt.Errorf("wal: restore set %d: %w", rec.ID, err)
+		}
+	case opRemove:
+		// Удаление отсутствующего элемента при восстановлении ничего не меняет.
+		if err := w.mem.RemoveByIndex(ctx, rec.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("wal: restore remove %d: %w", rec.ID, err)
+		}
+	case opClear:
+		return w.mem.Clear(ctx)
+	default:
+		return fmt.Errorf("wal: unknown operation %q", rec.Op)
+	}
+	return nil
+}
+
+func (w *WAL[T]) path(name string) string {
+	return filepath.Join(w.dir, name)
+}
@@ -0,0 +1,322 @@
+package wal
+
+import (
+	"context"
+	"NotesServer/gates/storage"
+	"NotesServer/gates/storage/storagetest"
+	"NotesServer/models/dto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+// crash закрывает журнал без снимка, как при аварийном завершении процесса.
+func crash(t *testing.T, w *WAL[item]) {
+	t.Helper()
+	if err := w.log.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// open открывает хранилище в dir и проверяет ошибку.
+func open(t *testing.T, dir string, snapshotEvery int64) *WAL[item] {
+	t.Helper()
+	w, err := NewWAL[item](dir, snapshotEvery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// contents возвращает элементы хранилища по индексам.
+func contents(t *testing.T, w *WAL[item]) map[int64]item {
+	t.Helper()
+	got := make(map[int64]item)
+	if err := w.Range(context.Background(), 1, func(id int64, v item) bool {
+		got[id] = v
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// fill выполняет над w все виды изменяющих операций и возвращает ожидаемое состояние.
+func fill(t *testing.T, w *WAL[item]) map[int64]item {
+	t.Helper()
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := w.Add(ctx, item{name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	steps := []error{
+		w.Update(ctx, 2, item{"b2"}),
+		w.RemoveByIndex(ctx, 3),
+		w.RemoveByValue(ctx, item{"d"}),
+		w.AddToIndex(ctx, item{"x"}, 10),
+		w.RemoveByIndex(ctx, 10), // последний индекс: после восстановления он не должен выдаваться снова
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	return map[int64]item{1: {"a"}, 2: {"b2"}, 5: {"e"}}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name string
+		// close завершает работу хранилища и возвращает изменения, сделанные после fill.
+		close func(t *testing.T, w *WAL[item]) map[int64]item
+	}{
+		{"replay log", func(t *testing.T, w *WAL[item]) map[int64]item {
+			crash(t, w)
+			return nil
+		}},
+		{"snapshot on Close", func(t *testing.T, w *WAL[item]) map[int64]item {
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			return nil
+		}},
+		{"snapshot and log", func(t *testing.T, w *WAL[item]) map[int64]item {
+			if err := w.Snapshot(); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Update(context.Background(), 5, item{"e2"}); err != nil {
+				t.Fatal(err)
+			}
+			crash(t, w)
+			return map[int64]item{5: {"e2"}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w := open(t, dir, 100)
+			want := fill(t, w)
+			for id, v := range tt.close(t, w) {
+				want[id] = v
+			}
+
+			w = open(t, dir, 100)
+			defer w.Close()
+			if got := contents(t, w); !reflect.DeepEqual(got, want) {
+				t.Errorf("restored %v, want %v", got, want)
+			}
+			if got := w.NextIndex(); got != 11 {
+				t.Errorf("NextIndex() = %d, want 11", got)
+			}
+		})
+	}
+}
+
+// TestClearRecover проверяет, что очистка восстанавливается, а индексы после нее не повторяются.
+func TestClearRecover(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	w := open(t, dir, 100)
+	fill(t, w)
+	if err := w.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, w)
+
+	w = open(t, dir, 100)
+	if w.Len() != 0 || w.NextIndex() != 11 {
+		t.Errorf("after replay: len %d, next index %d", w.Len(), w.NextIndex())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w = open(t, dir, 100)
+	defer w.Close()
+	if w.Len() != 0 || w.NextIndex() != 11 {
+		t.Errorf("after snapshot: len %d, next index %d", w.Len(), w.NextIndex())
+	}
+}
+
+// TestTornTail проверяет, что оборванная последняя запись журнала отбрасывается
+// и не склеивается со следующей.
+func TestTornTail(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	w := open(t, dir, 100)
+	want := fill(t, w)
+	crash(t, w)
+
+	logPath := filepath.Join(dir, logFileName)
+	valid, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"seq":99,"op":"add","id":11,"val`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	w = open(t, dir, 100)
+	if got := contents(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %v, want %v", got, want)
+	}
+	if info, err := os.Stat(logPath); err != nil || info.Size() != valid.Size() {
+		t.Errorf("log size after open: %v, %v, want %d", info.Size(), err, valid.Size())
+	}
+	id, err := w.Add(ctx, item{"f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[id] = item{"f"}
+	crash(t, w)
+
+	w = open(t, dir, 100)
+	defer w.Close()
+	if got := contents(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("after add: restored %v, want %v", got, want)
+	}
+}
+
+// TestCorruptRecord проверяет, что испорченная запись в середине журнала - ошибка,
+// а не молчаливая потеря следующих записей.
+func TestCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	w := open(t, dir, 100)
+	fill(t, w)
+	crash(t, w)
+
+	logPath := filepath.Join(dir, logFileName)
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append([]byte("not json\n"), data...)
+	if err := os.WriteFile(logPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWAL[item](dir, 100); err == nil {
+		t.Error("NewWAL() with a corrupt record: no error")
+	}
+}
+
+// TestSnapshotSeqSkip имитирует сбой между записью снимка и обрезкой журнала:
+// записи, уже вошедшие в снимок, при восстановлении пропускаются по Seq.
+func TestSnapshotSeqSkip(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	w := open(t, dir, 100)
+	want := fill(t, w)
+
+	logPath := filepath.Join(dir, logFileName)
+	before, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Update(ctx, 1, item{"a2"}); err != nil {
+		t.Fatal(err)
+	}
+	want[1] = item{"a2"}
+	crash(t, w)
+
+	// Журнал, который не успели обрезать: старые записи и новые после снимка.
+	after, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logPath, append(before, after...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w = open(t, dir, 100)
+	defer w.Close()
+	if got := contents(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %v, want %v", got, want)
+	}
+}
+
+// TestCompact проверяет, что после snapshotEvery записей журнал сжимается в снимок.
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	w := open(t, dir, 3)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if _, err := w.Add(ctx, item{name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.records != 1 {
+		t.Errorf("records after compaction = %d, want 1", w.records)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Errorf("no snapshot: %v", err)
+	}
+	crash(t, w)
+
+	w = open(t, dir, 3)
+	defer w.Close()
+	if w.Len() != 4 || w.NextIndex() != 5 {
+		t.Errorf("restored len %d, next index %d", w.Len(), w.NextIndex())
+	}
+}
+
+// TestWriteFailure проверяет, что операция, не записанная в журнал, не видна в памяти.
+func TestWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	w := open(t, t.TempDir(), 100)
+	if _, err := w.Add(ctx, item{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	// Закрытый файл журнала отказывает в записи.
+	crash(t, w)
+
+	steps := []struct {
+		name string
+		op   func() error
+	}{
+		{"Add", func() error { _, err := w.Add(ctx, item{"b"}); return err }},
+		{"AddToIndex", func() error { return w.AddToIndex(ctx, item{"c"}, 5) }},
+		{"Update", func() error { return w.Update(ctx, 1, item{"a2"}) }},
+		{"RemoveByIndex", func() error { return w.RemoveByIndex(ctx, 1) }},
+		{"Clear", func() error { return w.Clear(ctx) }},
+	}
+	for _, step := range steps {
+		if err := step.op(); err == nil {
+			t.Errorf("%s: no error with a closed log", step.name)
+		}
+	}
+	if got, want := contents(t, w), map[int64]item{1: {"a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("contents %v, want %v", got, want)
+	}
+	if got := w.NextIndex(); got != 2 {
+		t.Errorf("NextIndex() = %d, want 2", got)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(tb testing.TB) storage.Storage[*dto.Note] { return openT[*dto.Note](tb) })
+	storagetest.RunAny(t, func(tb testing.TB) storage.Storage[any] { return openT[any](tb) })
+}
+
+// openT открывает хранилище во временном каталоге и закрывает его после теста.
+func openT[T comparable](tb testing.TB) *WAL[T] {
+	tb.Helper()
+	w, err := NewWAL[T](tb.TempDir(), 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { w.Close() })
+	return w
+}
@@ -1,24 +1,233 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"log/slog"
 	"NotesServer/controller/httpserver"
 	"NotesServer/gates/storage"
 	"NotesServer/gates/storage/list"
 	"NotesServer/gates/storage/mp"
+	"NotesServer/gates/storage/sqlite"
+	"NotesServer/gates/storage/tenant"
+	"NotesServer/gates/storage/wal"
+	"NotesServer/models/dto"
+	"NotesServer/pkg"
+	"NotesServer/pkg/auth"
+	"NotesServer/pkg/config"
+	"NotesServer/pkg/logging"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
 func main() {
-	var st storage.Storage
-	var useList bool
-	flag.BoolVar(&useList, "list", false, "A boolean flag")
-	flag.Parse()
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run запускает сервер с настройками из аргументов args и ждет сигнала остановки.
+// Процесс завершает только main, поэтому при ошибке отложенные вызовы run успевают
+// закрыть хранилища арендаторов и файл журнала.
+func run(args []string) (err error) {
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config.Load(): %w", err)
+	}
+	fmt.Printf("effective configuration:\n%s", cfg)
+
+	// Все записи журнала, включая пакет log и ошибки net/http, идут в один файл в формате JSON.
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return fmt.Errorf("logging.ParseLevel(): %w", err)
+	}
+	logFile, err := logging.Open(cfg.Log.File, logging.RotateOptions{
+		MaxSize:    cfg.Log.MaxSize,
+		MaxAge:     cfg.Log.MaxAge,
+		MaxBackups: cfg.Log.MaxBackups,
+	})
+	if err != nil {
+		return fmt.Errorf("logging.Open(): %w", err)
+	}
+	defer logFile.Close()
+	logger := logging.New(logFile, level)
+	slog.SetDefault(logger)
+	if r, ok := logFile.(*logging.Rotator); ok {
+		go reopenOnHangup(r)
+	}
+
+	tenants := tenant.NewRegistry(func(name string) (*httpserver.Tenant, error) {
+		dir := cfg.Storage.DataDir
+		if name != tenant.Default {
+			dir = filepath.Join(cfg.Storage.DataDir, "tenants", name)
+		}
+		st, err := openStorage(cfg.Storage, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := httpserver.NewTenant(st)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		return t, nil
+	})
+	// Хранилища сохраняются и закрываются при любом выходе из run, в том числе по ошибке.
+	// К этому моменту сервер уже остановлен, и начатых запросов нет.
+	defer func() {
+		if closeErr := tenants.Close(); closeErr != nil {
+			err = errors.Join(err, failed("tenants.Close()", closeErr))
+		}
+		if err == nil {
+			logger.Info("stopped")
+		}
+	}()
+
+	opts := []httpserver.Option{
+		httpserver.WithLegacyEndpoints(cfg.Server.LegacyAPI),
+		httpserver.WithMetrics(cfg.Server.Metrics),
+		httpserver.WithTimeouts(httpserver.Timeouts{
+			Read:       cfg.Server.ReadTimeout,
+			ReadHeader: cfg.Server.ReadHeaderTimeout,
+			Write:      cfg.Server.WriteTimeout,
+			Idle:       cfg.Server.IdleTimeout,
+		}),
+		httpserver.WithLimits(httpserver.Limits{
+			MaxHeaderBytes: cfg.Limits.MaxHeaderBytes,
+			MaxBodyBytes:   cfg.Limits.MaxBodyBytes,
+			MaxPageSize:    cfg.Limits.MaxPageSize,
+		}),
+	}
+	if cfg.Auth.APIKeys != "" || cfg.Auth.JWTKey != "" {
+		a := &auth.Authenticator{}
+		if cfg.Auth.APIKeys != "" {
+			if a.Keys, err = auth.LoadAPIKeys(cfg.Auth.APIKeys); err != nil {
+				return failed("auth.LoadAPIKeys()", err)
+			}
+		}
+		if cfg.Auth.JWTKey != "" {
+			if a.JWT, err = auth.LoadJWTKey(cfg.Auth.JWTKey); err != nil {
+				return failed("auth.LoadJWTKey()", err)
+			}
+		}
+		opts = append(opts, httpserver.WithAuth(a))
 
-	if useList {
-		st = list.NewList()
+		if cfg.Auth.Policy != "" {
+			policy, err := auth.LoadPolicy(cfg.Auth.Policy)
+			if err != nil {
+				return failed("auth.LoadPolicy()", err)
+			}
+			opts = append(opts, httpserver.WithPolicy(policy))
+		}
 	} else {
-		st = mp.NewMap()
+		logger.Warn("authentication is disabled: set auth.api_keys or auth.jwt_key to enable it")
+	}
+
+	opts = append(opts, httpserver.WithLogger(logger))
+	hs := httpserver.NewHttpServer(cfg.Server.Addr, tenants, opts...)
+	logger.Info("listening", slog.String("addr", cfg.Server.Addr))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hs.Start()
+	}()
+	// shutdown ждет завершения начатых запросов; повторный сигнал завершает процесс сразу.
+	shutdown := func() {
+		stop()
+		logger.Info("shutting down: waiting for in-flight requests", slog.Duration("timeout", cfg.Server.ShutdownTimeout))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := hs.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err.Error(), slog.String(logging.KeyFunction, "hs.Shutdown()"))
+		}
+	}
+
+	// Сервер уже отвечает на /healthz, но /readyz вернет 200 только после того, как хранилище
+	// арендатора по умолчанию восстановится из журнала и сообщит, что готово. Хранилище
+	// открывается сразу, чтобы ошибки настройки были видны при запуске.
+	defaultTenant, err := tenants.Get(tenant.Default)
+	if err != nil {
+		shutdown()
+		return failed("tenants.Get(tenant.Default)", err)
+	}
+	if err := defaultTenant.Health(ctx); err != nil {
+		shutdown()
+		return failed("defaultTenant.Health(ctx)", err)
+	}
+	hs.SetReady(true)
+	logger.Info("ready")
+
+	select {
+	case err := <-errCh:
+		// Сервер не запустился (например, порт занят); хранилища закроет отложенный вызов.
+		if err != nil {
+			return failed("hs.Start()", err)
+		}
+	case <-ctx.Done():
+		shutdown()
+	}
+	return nil
+}
+
+// reopenOnHangup заново открывает файл журнала по сигналу SIGHUP,
+// чтобы внешняя ротация (например, logrotate) могла переместить файл.
+func reopenOnHangup(r *logging.Rotator) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := r.Reopen(); err != nil {
+			fmt.Fprintln(os.Stderr, "logging: reopen:", err)
+			continue
+		}
+		slog.Info("log file reopened")
+	}
+}
+
+// failed записывает ошибку err вызова function в журнал и возвращает ее для run.
+func failed(function string, err error) error {
+	attrs := []any{slog.String(logging.KeyFunction, function)}
+	if stack := pkg.StackOf(err); stack != nil {
+		attrs = append(attrs, slog.Any(logging.KeyStack, stack))
+	}
+	slog.Error(err.Error(), attrs...)
+	return fmt.Errorf("%s: %w", function, err)
+}
+
+// openStorage открывает хранилище с настройками cfg и данными в каталоге dir.
+func openStorage(cfg config.Storage, dir string) (storage.Storage[*dto.Note], error) {
+	switch cfg.Backend {
+	case "map":
+		return mp.NewMap[*dto.Note](), nil
+	case "list":
+		return list.NewList[*dto.Note](), nil
+	case "wal":
+		w, err := wal.NewWAL[*dto.Note](dir, cfg.SnapshotEvery)
+		if err != nil {
+			return nil, fmt.Errorf("wal.NewWAL(): %w", err)
+		}
+		return w, nil
+	case "sqlite":
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll(): %w", err)
+		}
+		s, err := sqlite.NewSQLite(filepath.Join(dir, "notes.db"))
+		if err != nil {
+			return nil, fmt.Errorf("sqlite.NewSQLite(): %w", err)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
 	}
-	hs := httpserver.NewHttpServer(":8080", st)
-	hs.Start()
 }
@@ -1,22 +1,38 @@
 package dto
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"NotesServer/pkg/validate"
+)
 
+// Note - заметка. Теги validate задают правила для полей, которые заполняет клиент
+// (см. validate.Struct), max - длина в символах; id, version и owner выставляет сервер.
 type Note struct {
 	ID       int64  `json:"id,omitempty"`
-	Name     string `json:"name,omitempty"`
-	LastName string `json:"last_name,omitempty"`
-	Note     string `json:"note,omitempty"`
+	Name     string `json:"name,omitempty" validate:"required,max=100"`
+	LastName string `json:"last_name,omitempty" validate:"required,max=100"`
+	Note     string `json:"note,omitempty" validate:"required,max=10000"`
+	Version  int64  `json:"version,omitempty"` // увеличивается при каждом изменении заметки
+	Owner    string `json:"owner,omitempty"`   // клиент, создавший заметку
 }
 
 func NewNote() *Note {
 	return &Note{ID: -1}
 }
 
+// SetID присваивает заметке индекс; хранилище вызывает его в Add (см. storage.IDSetter).
+func (n *Note) SetID(id int64) {
+	if n != nil {
+		n.ID = id
+	}
+}
+
 type Response struct {
 	Result string          `json:"result"`
 	Data   json.RawMessage `json:"data"`
 	Error  string          `json:"error"`
+	Code   string          `json:"code,omitempty"`   // стабильный код ошибки, например "not_found"
+	Errors validate.Errors `json:"errors,omitempty"` // ошибки полей тела запроса (ответ 422)
 }
 
 func (r *Response) Wrap(result string, data json.RawMessage, error string) {
@@ -24,3 +40,22 @@ func (r *Response) Wrap(result string, data json.RawMessage, error string) {
 	r.Error = error
 	r.Data = data
 }
+
+// SearchHit - заметка, найденная полнотекстовым поиском, и ее релевантность.
+type SearchHit struct {
+	Score float64 `json:"score"`
+	Note  *Note   `json:"note"`
+}
+
+// Problem - описание ошибки в формате application/problem+json (RFC 7807).
+// Code и Errors - расширения: те же код и ошибки полей, что в Response.
+type Problem struct {
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Status    int             `json:"status"`
+	Detail    string          `json:"detail,omitempty"`
+	Instance  string          `json:"instance,omitempty"`
+	Code      string          `json:"code"`
+	Errors    validate.Errors `json:"errors,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// APIKeys - набор статических API-ключей.
+//
+// Ключи хранятся в виде SHA-256, поэтому время поиска не зависит от совпадения
+// префикса ключа, а сами ключи не остаются в памяти.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]apiKey
+}
+
+type apiKey struct {
+	name   string
+	tenant string
+}
+
+// apiKeysFile - формат файла с API-ключами:
+//
+//	{"api_keys": [{"name": "ci", "key": "...", "tenant": "team-a"}]}
+//
+// Если tenant не указан, арендатором считается имя ключа.
+type apiKeysFile struct {
+	APIKeys []struct {
+		Name   string `json:"name"`
+		Key    string `json:"key"`
+		Tenant string `json:"tenant"`
+	} `json:"api_keys"`
+}
+
+// LoadAPIKeys читает API-ключи из JSON-файла path.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: read api keys: %w", err)
+	}
+	var file apiKeysFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("auth: decode api keys: %w", err)
+	}
+
+	keys := &APIKeys{keys: make(map[[sha256.Size]byte]apiKey, len(file.APIKeys))}
+	for _, k := range file.APIKeys {
+		if k.Name == "" || k.Key == "" {
+			return nil, errors.New("auth: api key must have a name and a key")
+		}
+		sum := sha256.Sum256([]byte(k.Key))
+		if _, exists := keys.keys[sum]; exists {
+			return nil, fmt.Errorf("auth: duplicate api key %q", k.Name)
+		}
+		if k.Tenant == "" {
+			k.Tenant = k.Name
+		}
+		keys.keys[sum] = apiKey{name: k.Name, tenant: k.Tenant}
+	}
+	return keys, nil
+}
+
+// Lookup возвращает имя ключа key и его арендатора.
+func (k *APIKeys) Lookup(key string) (name, tenant string, ok bool) {
+	info, ok := k.keys[sha256.Sum256([]byte(key))]
+	return info.name, info.tenant, ok
+}
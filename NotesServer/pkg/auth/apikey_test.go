@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAPIKeysLookup(t *testing.T) {
+	keys, err := LoadAPIKeys(writeFile(t, "keys.json", `{"api_keys":[
+		{"name":"ci","key":"secret-ci","tenant":"team-a"},
+		{"name":"alice","key":"secret-alice"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key          string
+		name, tenant string
+		ok           bool
+	}{
+		{"secret-ci", "ci", "team-a", true},
+		// Без tenant арендатором считается имя ключа.
+		{"secret-alice", "alice", "alice", true},
+		{"secret-c", "", "", false},
+		{"secret-ci ", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		name, tenant, ok := keys.Lookup(tt.key)
+		if name != tt.name || tenant != tt.tenant || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %q, %q, %v, want %q, %q, %v", tt.key, name, tenant, ok, tt.name, tt.tenant, tt.ok)
+		}
+	}
+}
+
+func TestLoadAPIKeysErrors(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"invalid json", `{"api_keys":[`},
+		{"wrong type", `{"api_keys":{"name":"ci"}}`},
+		{"missing name", `{"api_keys":[{"key":"k"}]}`},
+		{"missing key", `{"api_keys":[{"name":"ci"}]}`},
+		{"duplicate key", `{"api_keys":[{"name":"a","key":"k"},{"name":"b","key":"k"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadAPIKeys(writeFile(t, "keys.json", tt.content)); err == nil {
+				t.Error("LoadAPIKeys: no error")
+			}
+		})
+	}
+	if _, err := LoadAPIKeys(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadAPIKeys(missing): no error")
+	}
+}
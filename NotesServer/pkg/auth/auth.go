@@ -0,0 +1,89 @@
+// Package auth проверяет учетные данные HTTP-запросов: статические API-ключи
+// и JWT (HS256 или RS256) в заголовке Authorization: Bearer.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader - заголовок запроса с API-ключом.
+const APIKeyHeader = "X-API-Key"
+
+// Способы аутентификации в Principal.Method.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrNoCredentials - в запросе нет ни API-ключа, ни токена.
+	ErrNoCredentials = errors.New("auth: no credentials")
+	// ErrInvalidAPIKey - API-ключ неизвестен.
+	ErrInvalidAPIKey = errors.New("auth: invalid api key")
+	// ErrInvalidToken - токен не прошел проверку: неверный формат, алгоритм или подпись.
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrTokenExpired - срок действия токена истек или еще не наступил.
+	ErrTokenExpired = errors.New("auth: token expired or not yet valid")
+)
+
+// Principal - аутентифицированный клиент.
+type Principal struct {
+	Subject string // имя API-ключа или claim "sub" токена
+	Tenant  string // арендатор, к данным которого относится запрос
+	Method  string // MethodAPIKey или MethodJWT
+	Claims  Claims // claims токена; для API-ключа - nil
+}
+
+// Authenticator проверяет учетные данные запроса.
+// Нулевое поле отключает соответствующий способ аутентификации.
+type Authenticator struct {
+	Keys *APIKeys
+	JWT  *JWTVerifier
+}
+
+// Authenticate возвращает клиента, выполнившего запрос.
+//
+// Токен передается в заголовке "Authorization: Bearer <token>", API-ключ - в заголовке X-API-Key.
+// Если в запросе нет учетных данных, возвращается ErrNoCredentials.
+func (a *Authenticator) Authenticate(req *http.Request) (*Principal, error) {
+	if header := req.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || a.JWT == nil {
+			return nil, ErrInvalidToken
+		}
+		claims, err := a.JWT.Verify(strings.TrimSpace(token))
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{Subject: claims.Subject(), Tenant: claims.Tenant(), Method: MethodJWT, Claims: claims}, nil
+	}
+
+	if key := req.Header.Get(APIKeyHeader); key != "" {
+		if a.Keys == nil {
+			return nil, ErrInvalidAPIKey
+		}
+		name, tenant, ok := a.Keys.Lookup(key)
+		if !ok {
+			return nil, ErrInvalidAPIKey
+		}
+		return &Principal{Subject: name, Tenant: tenant, Method: MethodAPIKey}, nil
+	}
+
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// NewContext возвращает копию ctx с клиентом p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext возвращает клиента, сохраненного в ctx функцией NewContext.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	keys, err := LoadAPIKeys(writeFile(t, "keys.json", `{"api_keys":[{"name":"ci","key":"kc","tenant":"team-a"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := verifier(t, testSecret)
+	valid := token(t, HS256, claims(nil), hs256(testSecret))
+
+	tests := []struct {
+		name    string
+		a       *Authenticator
+		headers map[string]string
+		want    *Principal
+		wantErr error
+	}{
+		{"api key", &Authenticator{Keys: keys}, map[string]string{APIKeyHeader: "kc"},
+			&Principal{Subject: "ci", Tenant: "team-a", Method: MethodAPIKey}, nil},
+		{"unknown api key", &Authenticator{Keys: keys}, map[string]string{APIKeyHeader: "kx"}, nil, ErrInvalidAPIKey},
+		{"api keys disabled", &Authenticator{JWT: v}, map[string]string{APIKeyHeader: "kc"}, nil, ErrInvalidAPIKey},
+		{"jwt", &Authenticator{JWT: v}, map[string]string{"Authorization": "bearer " + valid},
+			&Principal{Subject: "alice", Tenant: "acme", Method: MethodJWT}, nil},
+		{"jwt disabled", &Authenticator{Keys: keys}, map[string]string{"Authorization": "Bearer " + valid}, nil, ErrInvalidToken},
+		{"basic scheme", &Authenticator{JWT: v}, map[string]string{"Authorization": "Basic YTpi"}, nil, ErrInvalidToken},
+		// Заголовок Authorization проверяется первым: неверный токен не заменяется API-ключом.
+		{"bad token with api key", &Authenticator{Keys: keys, JWT: v},
+			map[string]string{"Authorization": "Bearer x.y.z", APIKeyHeader: "kc"}, nil, ErrInvalidToken},
+		{"no credentials", &Authenticator{Keys: keys, JWT: v}, nil, nil, ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/notes", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			got, err := tt.a.Authenticate(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate: %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && (got.Subject != tt.want.Subject || got.Tenant != tt.want.Tenant || got.Method != tt.want.Method) {
+				t.Errorf("Authenticate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("FromContext(Background): ok")
+	}
+	p := &Principal{Subject: "ci"}
+	if got, ok := FromContext(NewContext(context.Background(), p)); !ok || got != p {
+		t.Errorf("FromContext = %v, %v, want %v", got, ok, p)
+	}
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Алгоритмы подписи JWT.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// minSecretLen - минимальная длина секрета HS256 (RFC 7518, раздел 3.2).
+const minSecretLen = 32
+
+// leeway - допустимое расхождение часов при проверке exp и nbf.
+const leeway = time.Minute
+
+// Claims - claims JWT.
+type Claims map[string]interface{}
+
+// Subject возвращает claim "sub".
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Tenant возвращает claim "tenant", а если его нет - "sub".
+func (c Claims) Tenant() string {
+	if tenant, ok := c["tenant"].(string); ok && tenant != "" {
+		return tenant
+	}
+	return c.Subject()
+}
+
+// Roles возвращает строки из claim "roles"; элементы другого типа пропускаются.
+func (c Claims) Roles() []string {
+	raw, _ := c["roles"].([]interface{})
+	roles := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if role, ok := r.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// JWTVerifier проверяет подпись и срок действия JWT.
+//
+// Алгоритм определяется ключом: для секрета принимаются только токены HS256,
+// для открытого ключа RSA - только RS256. Поле alg токена не может выбрать другой
+// алгоритм, поэтому открытый ключ нельзя использовать как секрет HMAC.
+type JWTVerifier struct {
+	alg    string
+	secret []byte
+	pub    *rsa.PublicKey
+	now    func() time.Time
+}
+
+// LoadJWTKey читает ключ проверки токенов из файла path.
+//
+// Файл в формате PEM с открытым ключом RSA (PUBLIC KEY, RSA PUBLIC KEY или CERTIFICATE)
+// включает RS256. Любой другой файл считается секретом HS256 длиной не меньше 32 байт;
+// пробельные символы в начале и конце файла отбрасываются.
+func LoadJWTKey(path string) (*JWTVerifier, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: read jwt key: %w", err)
+	}
+
+	if block, _ := pem.Decode(raw); block != nil {
+		pub, err := parseRSAPublicKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("auth: parse jwt key: %w", err)
+		}
+		return &JWTVerifier{alg: RS256, pub: pub, now: time.Now}, nil
+	}
+
+	secret := bytes.TrimSpace(raw)
+	if len(secret) < minSecretLen {
+		return nil, fmt.Errorf("auth: jwt secret must be at least %d bytes", minSecretLen)
+	}
+	return &JWTVerifier{alg: HS256, secret: secret, now: time.Now}, nil
+}
+
+// Alg возвращает алгоритм, который принимает v.
+func (v *JWTVerifier) Alg() string {
+	return v.alg
+}
+
+// Verify проверяет токен и возвращает его claims.
+//
+// Токен должен быть подписан алгоритмом v, содержать claims "sub" и "exp";
+// "exp" и "nbf" проверяются с допуском в одну минуту.
+func (v *JWTVerifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != v.alg {
+		return nil, fmt.Errorf("%w: unexpected alg %q", ErrInvalidToken, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := v.verifySignature(parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.Subject() == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) verifySignature(signingInput string, sig []byte) error {
+	switch v.alg {
+	case HS256:
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case RS256:
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(v.pub, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, v.alg)
+	}
+	return nil
+}
+
+// decodeSegment декодирует часть токена в base64url и разбирает ее как JSON.
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
+
+func parseRSAPublicKey(block *pem.Block) (*rsa.PublicKey, error) {
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("not an RSA public key")
+		}
+		return pub, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("certificate does not hold an RSA public key")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
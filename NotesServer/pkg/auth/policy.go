@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Permission - право на группу операций. Какое право нужно каждому эндпоинту,
+// определяет сервер; политика только выдает права ролям.
+type Permission string
+
+// Права на операции с заметками.
+const (
+	PermRead  Permission = "notes:read"  // чтение и поиск заметок
+	PermWrite Permission = "notes:write" // создание, изменение и удаление заметок
+	PermClear Permission = "notes:clear" // удаление всех заметок арендатора
+)
+
+// permAll в политике выдает роли все права.
+const permAll = "*"
+
+// ErrForbidden - у клиента нет права на операцию.
+var ErrForbidden = errors.New("auth: permission denied")
+
+// Policy - политика доступа: права ролей и роли клиентов.
+type Policy struct {
+	roles        map[string]map[Permission]bool
+	subjects     map[string][]string
+	defaultRoles []string
+}
+
+// policyFile - формат файла политики:
+//
+//	{
+//	  "roles": {
+//	    "reader": ["notes:read"],
+//	    "editor": ["notes:read", "notes:write"],
+//	    "admin":  ["*"]
+//	  },
+//	  "subjects": {"ci": ["editor"], "alice": ["admin"]},
+//	  "default_roles": ["reader"]
+//	}
+//
+// Роли клиента берутся из subjects по его имени (API-ключа или claim "sub")
+// и из claim "roles" токена. Клиент без ролей получает default_roles.
+type policyFile struct {
+	Roles        map[string][]string `json:"roles"`
+	Subjects     map[string][]string `json:"subjects"`
+	DefaultRoles []string            `json:"default_roles"`
+}
+
+// LoadPolicy читает политику доступа из JSON-файла path.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: read policy: %w", err)
+	}
+	var file policyFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("auth: decode policy: %w", err)
+	}
+
+	p := &Policy{
+		roles:        make(map[string]map[Permission]bool, len(file.Roles)),
+		subjects:     file.Subjects,
+		defaultRoles: file.DefaultRoles,
+	}
+	for role, perms := range file.Roles {
+		p.roles[role] = make(map[Permission]bool, len(perms))
+		for _, perm := range perms {
+			p.roles[role][Permission(perm)] = true
+		}
+	}
+	// Опечатка в имени роли молча лишила бы клиентов доступа, поэтому она считается ошибкой.
+	for subject, roles := range file.Subjects {
+		if err := p.checkRoles(roles); err != nil {
+			return nil, fmt.Errorf("auth: subject %q: %w", subject, err)
+		}
+	}
+	if err := p.checkRoles(file.DefaultRoles); err != nil {
+		return nil, fmt.Errorf("auth: default roles: %w", err)
+	}
+	return p, nil
+}
+
+// Roles возвращает роли клиента principal.
+func (p *Policy) Roles(principal *Principal) []string {
+	roles := append([]string(nil), p.subjects[principal.Subject]...)
+	roles = append(roles, principal.Claims.Roles()...)
+	if len(roles) == 0 {
+		return p.defaultRoles
+	}
+	return roles
+}
+
+// Allowed сообщает, есть ли у клиента principal право perm.
+// Неизвестные роли из токена не дают прав.
+func (p *Policy) Allowed(principal *Principal, perm Permission) bool {
+	for _, role := range p.Roles(principal) {
+		perms := p.roles[role]
+		if perms[perm] || perms[permAll] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) checkRoles(roles []string) error {
+	for _, role := range roles {
+		if _, ok := p.roles[role]; !ok {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	return nil
+}
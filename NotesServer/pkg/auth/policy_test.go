@@ -0,0 +1,51 @@
+package auth
+
+import "testing"
+
+func TestPolicyAllowed(t *testing.T) {
+	p, err := LoadPolicy(writeFile(t, "policy.json", `{
+		"roles":{"reader":["notes:read"],"editor":["notes:read","notes:write"],"admin":["*"]},
+		"subjects":{"ci":["editor"],"alice":["admin"]},
+		"default_roles":["reader"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		perm      Permission
+		want      bool
+	}{
+		{"subject role", &Principal{Subject: "ci"}, PermWrite, true},
+		{"subject role without perm", &Principal{Subject: "ci"}, PermClear, false},
+		{"wildcard", &Principal{Subject: "alice"}, PermClear, true},
+		{"default roles", &Principal{Subject: "bob"}, PermRead, true},
+		{"default roles without perm", &Principal{Subject: "bob"}, PermWrite, false},
+		{"token roles", &Principal{Subject: "bob", Claims: Claims{"roles": []interface{}{"editor"}}}, PermWrite, true},
+		// Роли из токена заменяют default_roles, а неизвестные роли не дают прав.
+		{"unknown token role", &Principal{Subject: "bob", Claims: Claims{"roles": []interface{}{"root"}}}, PermRead, false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(tt.principal, tt.perm); got != tt.want {
+			t.Errorf("%s: Allowed(%s) = %v, want %v", tt.name, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"invalid json", `{"roles":`},
+		{"unknown subject role", `{"roles":{"reader":["notes:read"]},"subjects":{"ci":["raeder"]}}`},
+		{"unknown default role", `{"roles":{"reader":["notes:read"]},"default_roles":["admin"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadPolicy(writeFile(t, "policy.json", tt.content)); err == nil {
+				t.Error("LoadPolicy: no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package config загружает настройки сервера.
+//
+// Настройки собираются в порядке возрастания приоритета: значения по умолчанию (Default),
+// файл YAML или TOML (флаг -config или переменная NOTES_CONFIG), переменные окружения
+// (тег env у поля) и флаги командной строки. Каждый следующий источник меняет только
+// те настройки, которые в нем заданы.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfig - переменная окружения с путем к файлу настроек.
+const EnvConfig = "NOTES_CONFIG"
+
+// Config - настройки сервера.
+type Config struct {
+	Server  Server  `yaml:"server" toml:"server"`
+	Storage Storage `yaml:"storage" toml:"storage"`
+	Log     Log     `yaml:"log" toml:"log"`
+	Auth    Auth    `yaml:"auth" toml:"auth"`
+	Limits  Limits  `yaml:"limits" toml:"limits"`
+}
+
+// Server - настройки HTTP-сервера.
+type Server struct {
+	Addr              string        `yaml:"addr" toml:"addr" env:"NOTES_ADDR"`
+	LegacyAPI         bool          `yaml:"legacy_api" toml:"legacy_api" env:"NOTES_LEGACY_API"`
+	Metrics           bool          `yaml:"metrics" toml:"metrics" env:"NOTES_METRICS"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"NOTES_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"NOTES_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"NOTES_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"NOTES_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"NOTES_SHUTDOWN_TIMEOUT"`
+}
+
+// Storage - настройки хранилища.
+type Storage struct {
+	Backend       string `yaml:"backend" toml:"backend" env:"NOTES_STORAGE"`
+	DataDir       string `yaml:"data_dir" toml:"data_dir" env:"NOTES_DATA_DIR"`
+	SnapshotEvery int64  `yaml:"snapshot_every" toml:"snapshot_every" env:"NOTES_SNAPSHOT_EVERY"`
+}
+
+// Log - настройки журнала.
+type Log struct {
+	File  string `yaml:"file" toml:"file" env:"NOTES_LOG_FILE"` // "-" - стандартный поток ошибок
+	Level string `yaml:"level" toml:"level" env:"NOTES_LOG_LEVEL"`
+	// Файл журнала заменяется новым, когда он больше MaxSize байт или в него пишут дольше MaxAge;
+	// хранятся MaxBackups последних сжатых файлов. Ноль отключает ограничение.
+	MaxSize    int64         `yaml:"max_size" toml:"max_size" env:"NOTES_LOG_MAX_SIZE"`
+	MaxAge     time.Duration `yaml:"max_age" toml:"max_age" env:"NOTES_LOG_MAX_AGE"`
+	MaxBackups int           `yaml:"max_backups" toml:"max_backups" env:"NOTES_LOG_MAX_BACKUPS"`
+}
+
+// Auth - файлы с учетными данными и политикой доступа. Пустой путь отключает соответствующую проверку.
+type Auth struct {
+	APIKeys string `yaml:"api_keys" toml:"api_keys" env:"NOTES_API_KEYS"`
+	JWTKey  string `yaml:"jwt_key" toml:"jwt_key" env:"NOTES_JWT_KEY"`
+	Policy  string `yaml:"policy" toml:"policy" env:"NOTES_POLICY"`
+}
+
+// Limits - ограничения размера запросов и ответов.
+type Limits struct {
+	MaxHeaderBytes int   `yaml:"max_header_bytes" toml:"max_header_bytes" env:"NOTES_MAX_HEADER_BYTES"`
+	MaxBodyBytes   int64 `yaml:"max_body_bytes" toml:"max_body_bytes" env:"NOTES_MAX_BODY_BYTES"`
+	MaxPageSize    int   `yaml:"max_page_size" toml:"max_page_size" env:"NOTES_MAX_PAGE_SIZE"`
+}
+
+// Допустимые значения Storage.Backend и Log.Level.
+var (
+	Backends  = []string{"map", "list", "wal", "sqlite"}
+	LogLevels = []string{"debug", "info", "warn", "error"}
+)
+
+// Default возвращает настройки по умолчанию.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			LegacyAPI:         true,
+			Metrics:           true,
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		Storage: Storage{
+			Backend:       "map",
+			DataDir:       "data",
+			SnapshotEvery: 1000,
+		},
+		Log: Log{
+			File:       "logs.txt",
+			Level:      "info",
+			MaxSize:    100 << 20,
+			MaxAge:     24 * time.Hour,
+			MaxBackups: 7,
+		},
+		Limits: Limits{
+			MaxHeaderBytes: 1 << 20,
+			MaxBodyBytes:   1 << 20,
+			MaxPageSize:    1000,
+		},
+	}
+}
+
+// Load собирает настройки из файла, переменных окружения и флагов args (без имени программы)
+// и проверяет их. Если среди args есть -h или -help, возвращается flag.ErrHelp.
+func Load(args []string) (*Config, error) {
+	// Первый проход нужен только для того, чтобы узнать путь к файлу:
+	// флаги применяются после файла и окружения, поэтому разбираются второй раз.
+	path := os.Getenv(EnvConfig)
+	probe := Default()
+	if err := newFlagSet(&probe, &path).Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := newFlagSet(&cfg, &path).Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// newFlagSet создает флаги, записывающие значения в cfg. Значения флагов по умолчанию -
+// текущие значения cfg, поэтому незаданный флаг ничего не меняет.
+func newFlagSet(cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "YAML or TOML configuration file (env "+EnvConfig+")")
+
+	fs.StringVar(&cfg.Server.Addr, "addr", cfg.Server.Addr, "Listen address")
+	fs.BoolVar(&cfg.Server.LegacyAPI, "legacy-api", cfg.Server.LegacyAPI, "Serve the legacy POST endpoints (/create, /get, /update, /delete, /get-all)")
+	fs.BoolVar(&cfg.Server.Metrics, "metrics", cfg.Server.Metrics, "Serve Prometheus metrics at /metrics")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", cfg.Server.ReadTimeout, "Maximum duration for reading a whole request")
+	fs.DurationVar(&cfg.Server.ReadHeaderTimeout, "read-header-timeout", cfg.Server.ReadHeaderTimeout, "Maximum duration for reading request headers")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "Maximum duration before timing out writes of a response")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "How long to keep idle keep-alive connections open")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "How long to wait for in-flight requests on SIGINT or SIGTERM before closing connections")
+
+	fs.StringVar(&cfg.Storage.Backend, "storage", cfg.Storage.Backend, "Storage backend: "+strings.Join(Backends, ", "))
+	fs.StringVar(&cfg.Storage.DataDir, "data-dir", cfg.Storage.DataDir, "Directory for the wal and sqlite backends; other tenants are kept in <data-dir>/tenants/<name>")
+	fs.Int64Var(&cfg.Storage.SnapshotEvery, "snapshot-every", cfg.Storage.SnapshotEvery, "Number of wal log records after which a snapshot is written")
+
+	fs.StringVar(&cfg.Log.File, "log-file", cfg.Log.File, "File for the JSON log; \"-\" writes to stderr")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "Log level: "+strings.Join(LogLevels, ", "))
+	fs.Int64Var(&cfg.Log.MaxSize, "log-max-size", cfg.Log.MaxSize, "Rotate the log file when it exceeds this many bytes; 0 disables")
+	fs.DurationVar(&cfg.Log.MaxAge, "log-max-age", cfg.Log.MaxAge, "Rotate the log file after writing to it for this long; 0 disables")
+	fs.IntVar(&cfg.Log.MaxBackups, "log-max-backups", cfg.Log.MaxBackups, "Number of compressed rotated log files to keep; 0 keeps all")
+
+	fs.StringVar(&cfg.Auth.APIKeys, "api-keys", cfg.Auth.APIKeys, "JSON file with static API keys; enables authentication")
+	fs.StringVar(&cfg.Auth.JWTKey, "jwt-key", cfg.Auth.JWTKey, "HS256 secret or RS256 PEM public key for JWT bearer tokens; enables authentication")
+	fs.StringVar(&cfg.Auth.Policy, "policy", cfg.Auth.Policy, "JSON file with role-based access policy; requires -api-keys or -jwt-key")
+
+	fs.IntVar(&cfg.Limits.MaxHeaderBytes, "max-header-bytes", cfg.Limits.MaxHeaderBytes, "Maximum size of request headers in bytes")
+	fs.Int64Var(&cfg.Limits.MaxBodyBytes, "max-body-bytes", cfg.Limits.MaxBodyBytes, "Maximum size of a request body in bytes")
+	fs.IntVar(&cfg.Limits.MaxPageSize, "max-page-size", cfg.Limits.MaxPageSize, "Maximum value of the limit query parameter")
+	return fs
+}
+
+// loadFile читает настройки из файла path; формат определяется расширением.
+// Неизвестные ключи считаются ошибкой, чтобы опечатка не оставляла значение по умолчанию.
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config: decode %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(raw), c)
+		if err != nil {
+			return fmt.Errorf("config: decode %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config: decode %s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("config: %s: unsupported format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	return nil
+}
+
+// applyEnv записывает в поля v значения переменных окружения из тега env.
+func applyEnv(v reflect.Value, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(field, raw); err != nil {
+			return fmt.Errorf("config: env %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setValue разбирает raw в соответствии с типом поля field.
+func setValue(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("config: "+format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	check(c.Server.ReadHeaderTimeout >= 0, "server.read_header_timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	check(contains(Backends, c.Storage.Backend), "storage.backend %q must be one of %s", c.Storage.Backend, strings.Join(Backends, ", "))
+	check(c.Storage.DataDir != "" || c.Storage.Backend == "map" || c.Storage.Backend == "list", "storage.data_dir must not be empty for the %s backend", c.Storage.Backend)
+	check(c.Storage.SnapshotEvery > 0, "storage.snapshot_every must be positive")
+
+	check(c.Log.File != "", "log.file must not be empty")
+	check(contains(LogLevels, c.Log.Level), "log.level %q must be one of %s", c.Log.Level, strings.Join(LogLevels, ", "))
+	check(c.Log.MaxSize >= 0, "log.max_size must not be negative")
+	check(c.Log.MaxAge >= 0, "log.max_age must not be negative")
+	check(c.Log.MaxBackups >= 0, "log.max_backups must not be negative")
+
+	check(c.Auth.Policy == "" || c.Auth.APIKeys != "" || c.Auth.JWTKey != "", "auth.policy requires auth.api_keys or auth.jwt_key")
+
+	check(c.Limits.MaxHeaderBytes > 0, "limits.max_header_bytes must be positive")
+	check(c.Limits.MaxBodyBytes > 0, "limits.max_body_bytes must be positive")
+	check(c.Limits.MaxPageSize > 0, "limits.max_page_size must be positive")
+
+	return errors.Join(errs...)
+}
+
+// String возвращает настройки в формате YAML, в котором их можно сохранить в файл.
+func (c *Config) String() string {
+	raw, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(raw)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile записывает настройки в файл name во временном каталоге и возвращает путь к нему.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadLayers проверяет порядок источников: значения по умолчанию < файл < окружение < флаги.
+func TestLoadLayers(t *testing.T) {
+	yamlFile := writeFile(t, "notes.yaml", `
+server:
+  addr: ":1001"
+  read_timeout: 5s
+storage:
+  backend: wal
+  snapshot_every: 10
+log:
+  level: debug
+`)
+	tomlFile := writeFile(t, "notes.toml", `
+[server]
+addr = ":1001"
+read_timeout = "5s"
+
+[storage]
+backend = "wal"
+snapshot_every = 10
+
+[log]
+level = "debug"
+`)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want func(c *Config)
+	}{
+		{
+			name: "default",
+			want: func(c *Config) {},
+		},
+		{
+			name: "yaml file",
+			env:  map[string]string{EnvConfig: yamlFile},
+			want: func(c *Config) {
+				c.Server.Addr = ":1001"
+				c.Server.ReadTimeout = 5 * time.Second
+				c.Storage.Backend = "wal"
+				c.Storage.SnapshotEvery = 10
+				c.Log.Level = "debug"
+			},
+		},
+		{
+			name: "toml file from flag",
+			args: []string{"-config", tomlFile},
+			want: func(c *Config) {
+				c.Server.Addr = ":1001"
+				c.Server.ReadTimeout = 5 * time.Second
+				c.Storage.Backend = "wal"
+				c.Storage.SnapshotEvery = 10
+				c.Log.Level = "debug"
+			},
+		},
+		{
+			name: "env over file",
+			env: map[string]string{
+				EnvConfig:              yamlFile,
+				"NOTES_ADDR":           ":1002",
+				"NOTES_LOG_LEVEL":      "warn",
+				"NOTES_LEGACY_API":     "false",
+				"NOTES_LOG_MAX_AGE":    "1h",
+				"NOTES_MAX_PAGE_SIZE":  "50",
+				"NOTES_SNAPSHOT_EVERY": "20",
+			},
+			want: func(c *Config) {
+				c.Server.Addr = ":1002"
+				c.Server.LegacyAPI = false
+				c.Server.ReadTimeout = 5 * time.Second
+				c.Storage.Backend = "wal"
+				c.Storage.SnapshotEvery = 20
+				c.Log.Level = "warn"
+				c.Log.MaxAge = time.Hour
+				c.Limits.MaxPageSize = 50
+			},
+		},
+		{
+			name: "flags over env and file",
+			env: map[string]string{
+				EnvConfig:         yamlFile,
+				"NOTES_ADDR":      ":1002",
+				"NOTES_LOG_LEVEL": "warn",
+			},
+			args: []string{"-addr", ":1003", "-storage", "sqlite", "-read-timeout", "7s"},
+			want: func(c *Config) {
+				c.Server.Addr = ":1003"
+				c.Server.ReadTimeout = 7 * time.Second
+				c.Storage.Backend = "sqlite"
+				c.Storage.SnapshotEvery = 10
+				c.Log.Level = "warn"
+			},
+		},
+		{
+			// Флаг со значением по умолчанию все равно задан явно и перекрывает файл.
+			name: "explicit flag equal to default",
+			env:  map[string]string{EnvConfig: yamlFile},
+			args: []string{"-log-level", "info"},
+			want: func(c *Config) {
+				c.Server.Addr = ":1001"
+				c.Server.ReadTimeout = 5 * time.Second
+				c.Storage.Backend = "wal"
+				c.Storage.SnapshotEvery = 10
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvConfig, "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			got, err := Load(tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			want := Default()
+			tt.want(&want)
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("Load:\n%s\nwant:\n%s", got, &want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown yaml key", file: "notes.yaml", content: "server:\n  adr: \":1\"\n", wantErr: "field adr not found"},
+		{name: "unknown toml key", file: "notes.toml", content: "[server]\nadr = \":1\"\n", wantErr: `unknown key "server.adr"`},
+		{name: "unsupported format", file: "notes.json", content: "{}", wantErr: "unsupported format"},
+		{name: "bad env duration", env: map[string]string{"NOTES_READ_TIMEOUT": "5"}, wantErr: "env NOTES_READ_TIMEOUT"},
+		{name: "bad env bool", env: map[string]string{"NOTES_METRICS": "maybe"}, wantErr: "env NOTES_METRICS"},
+		{name: "unknown flag", args: []string{"-adr", ":1"}, wantErr: "flag provided but not defined"},
+		{name: "invalid result", args: []string{"-storage", "redis"}, wantErr: `storage.backend "redis"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvConfig, "")
+			if tt.file != "" {
+				t.Setenv(EnvConfig, writeFile(t, tt.file, tt.content))
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load: error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	t.Setenv(EnvConfig, "")
+	if _, err := Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h): error %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string // фрагменты ожидаемых ошибок
+	}{
+		{"default", func(c *Config) {}, nil},
+		{"empty addr", func(c *Config) { c.Server.Addr = "" }, []string{"server.addr"}},
+		{"negative timeout", func(c *Config) { c.Server.ReadTimeout = -1 }, []string{"server.read_timeout"}},
+		{"zero shutdown timeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }, []string{"server.shutdown_timeout"}},
+		{"unknown backend", func(c *Config) { c.Storage.Backend = "redis" }, []string{"storage.backend"}},
+		{"map without data dir", func(c *Config) { c.Storage.DataDir = "" }, nil},
+		{"wal without data dir", func(c *Config) {
+			c.Storage.Backend = "wal"
+			c.Storage.DataDir = ""
+		}, []string{"storage.data_dir"}},
+		{"zero snapshot every", func(c *Config) { c.Storage.SnapshotEvery = 0 }, []string{"storage.snapshot_every"}},
+		{"empty log file", func(c *Config) { c.Log.File = "" }, []string{"log.file"}},
+		{"unknown log level", func(c *Config) { c.Log.Level = "trace" }, []string{"log.level"}},
+		{"zero rotation", func(c *Config) {
+			c.Log.MaxSize = 0
+			c.Log.MaxAge = 0
+			c.Log.MaxBackups = 0
+		}, nil},
+		{"negative rotation", func(c *Config) {
+			c.Log.MaxSize = -1
+			c.Log.MaxAge = -1
+			c.Log.MaxBackups = -1
+		}, []string{"log.max_size", "log.max_age", "log.max_backups"}},
+		{"policy without auth", func(c *Config) { c.Auth.Policy = "policy.json" }, []string{"auth.policy"}},
+		{"policy with jwt", func(c *Config) {
+			c.Auth.Policy = "policy.json"
+			c.Auth.JWTKey = "secret"
+		}, nil},
+		{"zero limits", func(c *Config) { c.Limits = Limits{} }, []string{"limits.max_header_bytes", "limits.max_body_bytes", "limits.max_page_size"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(&c)
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate: no error, want %v", tt.want)
+			}
+			// Validate возвращает все ошибки сразу, по одной на строку.
+			if lines := strings.Split(err.Error(), "\n"); len(lines) != len(tt.want) {
+				t.Errorf("Validate: %d errors, want %d: %v", len(lines), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate: error %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -1,29 +1,28 @@
 package pkg
 
 import (
-	"errors"
-	"fmt"
-	"log"
-	"os"
+	"context"
+	"log/slog"
+	"NotesServer/pkg/logging"
 )
 
+// EWrapper оборачивает ошибки функции functionName и записывает их в структурированный журнал.
 type EWrapper struct {
 	functionName string
 	comment      string
 	err          error
-	logFile      *os.File
+	logger       *slog.Logger
 }
 
+// NewEWrapper создает EWrapper, записывающий ошибки через slog.Default().
 func NewEWrapper(f string) *EWrapper {
-	return &EWrapper{f, "", nil, nil}
+	return NewEWrapperWithLogger(f, slog.Default())
 }
 
-func NewEWrapperWithFile(f string) (*EWrapper, error) {
-	file, err := os.OpenFile("logs.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) // May be any file
-	if err != nil {
-		return nil, err
-	}
-	return &EWrapper{f, "", nil, file}, nil
+// NewEWrapperWithLogger создает EWrapper, записывающий ошибки через logger,
+// например через логгер запроса с его идентификатором.
+func NewEWrapperWithLogger(f string, logger *slog.Logger) *EWrapper {
+	return &EWrapper{functionName: f, logger: logger}
 }
 
 func (e *EWrapper) Wrap(err error, comment string) *EWrapper {
@@ -34,29 +33,47 @@ func (e *EWrapper) Wrap(err error, comment string) *EWrapper {
 	return e
 }
 
+// Error возвращает сохраненную ошибку, обернутую в Error с операцией "функция: вызов",
+// или nil, если ошибки не было. Исходная ошибка доступна через errors.Is и errors.As.
 func (e *EWrapper) Error() error {
 	if e.err == nil {
 		return nil
 	}
-	return errors.New(e.comment + "    __IN__    " + e.functionName + ":\n" + e.err.Error() + "\n")
+	return &Error{Op: e.op(), Err: e.err}
 }
 
+// WrapError оборачивает ошибку err вызова comment так же, как Error, и сохраняет стек.
+// Если err равна nil, возвращается nil.
 func (e *EWrapper) WrapError(err error, comment string) error {
-	if err != nil {
-		return e.Wrap(err, comment).Error()
+	if err == nil {
+		return nil
 	}
-	return nil
+	e.Wrap(err, comment)
+	return withStack(e.op(), e.err)
 }
 
+// LogError записывает ошибку err вызова comment с уровнем Error.
+// Текст ошибки - сообщение записи, handler - функция EWrapper, function - вызов comment.
 func (e *EWrapper) LogError(err error, comment string) {
-	if err != nil {
-		e.comment = comment
-		log.Println("\033[31m", e.Wrap(err, comment).Error(), "\033[0m")
-		fmt.Fprintln(e.logFile, e.Wrap(err, comment).Error())
-
+	if err == nil {
+		return
+	}
+	e.Wrap(err, comment)
+	attrs := []slog.Attr{
+		slog.String(logging.KeyHandler, e.functionName),
+		slog.String(logging.KeyFunction, comment),
+	}
+	if stack := StackOf(err); stack != nil {
+		attrs = append(attrs, slog.Any(logging.KeyStack, stack))
 	}
+	e.logger.LogAttrs(context.Background(), slog.LevelError, err.Error(), attrs...)
 }
 
+func (e *EWrapper) op() string {
+	return e.functionName + ": " + e.comment
+}
+
+// Close ничего не делает: журнал общий для всех EWrapper. Оставлен для совместимости.
 func (e *EWrapper) Close() error {
-	return e.logFile.Close()
+	return nil
 }
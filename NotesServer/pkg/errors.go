@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"NotesServer/pkg/logging"
+	"runtime"
+)
+
+// maxStackDepth - наибольшее количество кадров стека, сохраняемых в Error.
+const maxStackDepth = 32
+
+// Error - ошибка операции Op, вызванная ошибкой Err. Создается EWrapper.Error
+// и EWrapper.WrapError (со стеком).
+//
+// Error реализует Unwrap, поэтому errors.Is и errors.As видят исходную ошибку
+// (например, storage.ErrNotFound) сквозь любое количество оберток.
+type Error struct {
+	Op    string    // операция, например "(hs *HttpServer) Start(): hs.srv.ListenAndServe()"
+	Err   error     // исходная ошибка
+	stack []uintptr // место создания ошибки; пусто, если стек не сохранялся
+}
+
+// withStack создает Error со стеком, начиная с функции, вызвавшей вызывающую withStack.
+func withStack(op string, err error) *Error {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+	return &Error{Op: op, Err: err, stack: pcs[:n]}
+}
+
+func (e *Error) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Stack возвращает сохраненный стек в виде строк "функция (файл:строка)", начиная с места создания ошибки.
+func (e *Error) Stack() []string {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	frames := runtime.CallersFrames(e.stack)
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line))
+		if !more {
+			return stack
+		}
+	}
+}
+
+// LogValue представляет ошибку в журнале группой полей: op, cause и stack,
+// если стек сохранен у этой или вложенной ошибки Error.
+func (e *Error) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("op", e.Op),
+		slog.String("cause", e.Err.Error()),
+	}
+	if stack := StackOf(e); stack != nil {
+		attrs = append(attrs, slog.Any(logging.KeyStack, stack))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// StackOf возвращает стек самой глубокой ошибки Error в цепочке err, у которой он сохранен.
+func StackOf(err error) []string {
+	var stack []string
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			break
+		}
+		if s := e.Stack(); s != nil {
+			stack = s
+		}
+		err = e.Err
+	}
+	return stack
+}
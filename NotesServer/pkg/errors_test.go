@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestErrorUnwrap проверяет, что errors.Is и errors.As находят исходную ошибку
+// и саму Error сквозь обертки EWrapper и fmt.Errorf.
+func TestErrorUnwrap(t *testing.T) {
+	errBase := errors.New("base")
+	pathErr := &fs.PathError{Op: "open", Path: "notes.db", Err: fs.ErrNotExist}
+
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		op     string
+		text   string
+	}{
+		{
+			name:   "Error",
+			err:    NewEWrapper("f()").Wrap(errBase, "g()").Error(),
+			target: errBase,
+			op:     "f(): g()",
+			text:   "f(): g(): base",
+		},
+		{
+			name:   "WrapError",
+			err:    NewEWrapper("f()").WrapError(pathErr, "os.Open()"),
+			target: fs.ErrNotExist,
+			op:     "f(): os.Open()",
+			text:   "f(): os.Open(): open notes.db: file does not exist",
+		},
+		{
+			name:   "fmt.Errorf over Error",
+			err:    fmt.Errorf("outer: %w", NewEWrapper("f()").WrapError(errBase, "g()")),
+			target: errBase,
+			op:     "f(): g()",
+			text:   "outer: f(): g(): base",
+		},
+		{
+			name:   "Error over Error",
+			err:    NewEWrapper("f()").Wrap(NewEWrapper("g()").WrapError(pathErr, "h()"), "g()").Error(),
+			target: os.ErrNotExist,
+			op:     "f(): g()",
+			text:   "f(): g(): g(): h(): open notes.db: file does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.text {
+				t.Errorf("Error() = %q, want %q", got, tt.text)
+			}
+			if !errors.Is(tt.err, tt.target) {
+				t.Errorf("errors.Is(%v, %v) = false", tt.err, tt.target)
+			}
+			var e *Error
+			if !errors.As(tt.err, &e) {
+				t.Errorf("errors.As(%v, *Error) = false", tt.err)
+			} else if e.Op != tt.op {
+				t.Errorf("errors.As(*Error): op %q, want %q", e.Op, tt.op)
+			}
+			if tt.target == fs.ErrNotExist || tt.target == os.ErrNotExist {
+				var pe *fs.PathError
+				if !errors.As(tt.err, &pe) || pe.Path != "notes.db" {
+					t.Errorf("errors.As(*fs.PathError) = %v", pe)
+				}
+			}
+		})
+	}
+}
+
+func TestEWrapperNil(t *testing.T) {
+	eW := NewEWrapper("f()")
+	if err := eW.Error(); err != nil {
+		t.Errorf("Error() = %v, want nil", err)
+	}
+	if err := eW.WrapError(nil, "g()"); err != nil {
+		t.Errorf("WrapError(nil) = %v, want nil", err)
+	}
+}
+
+// TestStackOf проверяет, что стек сохраняет только WrapError и что он начинается с места вызова.
+func TestStackOf(t *testing.T) {
+	if stack := StackOf(NewEWrapper("f()").Wrap(errors.New("x"), "g()").Error()); stack != nil {
+		t.Errorf("Error() has stack %v", stack)
+	}
+	if stack := StackOf(errors.New("x")); stack != nil {
+		t.Errorf("plain error has stack %v", stack)
+	}
+
+	inner := NewEWrapper("g()").WrapError(errors.New("x"), "h()")
+	err := fmt.Errorf("wrapped: %w", NewEWrapper("f()").Wrap(inner, "g()").Error())
+	stack := StackOf(err)
+	if len(stack) == 0 || !strings.Contains(stack[0], "TestStackOf") {
+		t.Errorf("StackOf() = %v, want stack starting in TestStackOf", stack)
+	}
+}
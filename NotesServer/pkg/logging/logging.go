@@ -0,0 +1,85 @@
+// Package logging настраивает структурированный журнал сервера: записи log/slog
+// в формате JSON, по одной на строку, в общий для всех обработчиков файл.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Ключи полей записей журнала.
+const (
+	KeyRequestID = "request_id"
+	KeyHandler   = "handler"  // обработчик или метод, в котором произошла ошибка
+	KeyFunction  = "function" // вызов, вернувший ошибку
+	KeyStack     = "stack"    // стек места ошибки, если он сохранен (pkg.EWrapper.WrapError)
+)
+
+// Stderr - путь к журналу, означающий стандартный поток ошибок.
+const Stderr = "-"
+
+// ParseLevel разбирает уровень журнала: debug, info, warn или error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("logging: invalid level %q", s)
+	}
+	return level, nil
+}
+
+// Open открывает журнал path для дописывания: файл заменяется по правилам opts (см. Rotator).
+// Для Stderr возвращается os.Stderr, который Close не закрывает.
+func Open(path string, opts RotateOptions) (io.WriteCloser, error) {
+	if path == Stderr {
+		return nopCloser{os.Stderr}, nil
+	}
+	return NewRotator(path, opts)
+}
+
+// New создает логгер, записывающий в w записи уровня level и выше в формате JSON.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+type loggerKey struct{}
+
+// NewContext возвращает копию ctx с логгером l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext возвращает логгер, сохраненный в ctx функцией NewContext, или slog.Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// maxRequestIDLen - наибольшая длина идентификатора запроса, принятого от клиента.
+const maxRequestIDLen = 128
+
+// RequestID возвращает идентификатор запроса: id от клиента (например, из заголовка
+// X-Request-ID), если он непустой и состоит из видимых символов ASCII, иначе новый случайный.
+func RequestID(id string) string {
+	if id != "" && len(id) <= maxRequestIDLen && strings.IndexFunc(id, func(r rune) bool { return r <= ' ' || r > '~' }) < 0 {
+		return id
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
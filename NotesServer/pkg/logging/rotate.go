@@ -0,0 +1,248 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat - метка времени в имени старого файла журнала; такие имена
+// сортируются по времени как строки.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotateOptions - когда менять файл журнала и сколько старых файлов хранить.
+// Нулевое поле отключает соответствующее ограничение.
+type RotateOptions struct {
+	MaxSize    int64         // наибольший размер файла в байтах
+	MaxAge     time.Duration // наибольшее время записи в один файл
+	MaxBackups int           // сколько сжатых старых файлов хранить; 0 - все
+}
+
+// Rotator - файл журнала, который заменяется новым при достижении MaxSize или MaxAge.
+//
+// Старый файл переименовывается в <path>.<время>.gz и сжимается в фоне; из сжатых
+// файлов хранятся MaxBackups последних. Reopen заново открывает path, например после
+// того, как файл переместила внешняя программа (SIGHUP). Безопасен для одновременного
+// использования, поэтому один Rotator можно разделить между всеми обработчиками.
+type Rotator struct {
+	path string
+	opts RotateOptions
+
+	mtx    sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+
+	bg sync.WaitGroup // фоновое сжатие старых файлов
+	// bgMtx сериализует сжатие и удаление старых файлов, чтобы удаление не застало незаконченный архив.
+	bgMtx sync.Mutex
+}
+
+// NewRotator открывает файл журнала path для дописывания.
+func NewRotator(path string, opts RotateOptions) (*Rotator, error) {
+	r := &Rotator{path: path, opts: opts, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write дописывает p в файл журнала, предварительно заменяя файл, если он переполнен или устарел.
+// Запись не делится между файлами.
+func (r *Rotator) Write(p []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.due(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate заменяет файл журнала новым независимо от его размера и возраста.
+func (r *Rotator) Rotate() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// Reopen закрывает и заново открывает файл path.
+func (r *Rotator) Reopen() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("logging: close %s: %w", r.path, err)
+	}
+	r.file = nil
+	return r.open()
+}
+
+// Close закрывает файл журнала и дожидается сжатия старых файлов.
+func (r *Rotator) Close() error {
+	r.mtx.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mtx.Unlock()
+
+	r.bg.Wait()
+	return err
+}
+
+// due сообщает, что перед записью n байт файл нужно заменить; вызывается под блокировкой.
+// Пустой файл не заменяется, чтобы слишком большая запись не порождала пустые архивы.
+func (r *Rotator) due(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSize > 0 && r.size+n > r.opts.MaxSize {
+		return true
+	}
+	return r.opts.MaxAge > 0 && r.now().Sub(r.opened) >= r.opts.MaxAge
+}
+
+// rotate переименовывает текущий файл, открывает новый и запускает сжатие старого;
+// вызывается под блокировкой.
+func (r *Rotator) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("logging: close %s: %w", r.path, err)
+	}
+	r.file = nil
+
+	backup := r.path + "." + r.now().Format(backupTimeFormat)
+	err := os.Rename(r.path, backup)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// Файл удалили снаружи: сжимать нечего, просто начинаем новый.
+		return r.open()
+	case err != nil:
+		// Файл не удалось переименовать: продолжаем писать в него, чтобы не терять записи.
+		if errOpen := r.open(); errOpen != nil {
+			return errOpen
+		}
+		return fmt.Errorf("logging: rotate %s: %w", r.path, err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.bg.Add(1)
+	go func() {
+		defer r.bg.Done()
+		r.bgMtx.Lock()
+		defer r.bgMtx.Unlock()
+
+		if err := compress(backup); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if err := r.removeOld(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	return nil
+}
+
+// open открывает path и запоминает его размер; вызывается под блокировкой.
+func (r *Rotator) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("logging: open %s: %w", r.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("logging: stat %s: %w", r.path, err)
+	}
+	r.file, r.size, r.opened = f, info.Size(), r.now()
+	return nil
+}
+
+// removeOld удаляет сжатые файлы журнала сверх MaxBackups, начиная с самых старых.
+func (r *Rotator) removeOld() error {
+	if r.opts.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(globEscape(r.path) + ".*.gz")
+	if err != nil {
+		return fmt.Errorf("logging: list backups of %s: %w", r.path, err)
+	}
+	if len(backups) <= r.opts.MaxBackups {
+		return nil
+	}
+	sort.Strings(backups)
+	var errs []error
+	for _, name := range backups[:len(backups)-r.opts.MaxBackups] {
+		if err := os.Remove(name); err != nil {
+			errs = append(errs, fmt.Errorf("logging: remove old backup: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compress сжимает файл name в name.gz и удаляет исходный файл.
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("logging: compress %s: %w", name, err)
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("logging: compress %s: %w", name, err)
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if errClose := zw.Close(); err == nil {
+		err = errClose
+	}
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("logging: compress %s: %w", name, err)
+	}
+	return os.Remove(name)
+}
+
+// globEscape экранирует метасимволы filepath.Match в пути.
+func globEscape(path string) string {
+	var out []rune
+	for _, c := range path {
+		switch c {
+		case '*', '?', '[', '\\':
+			out = append(out, '\\')
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
@@ -0,0 +1,219 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// clock - подменяемые часы для Rotator.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newRotator открывает журнал во временном каталоге с часами c.
+func newRotator(t *testing.T, opts RotateOptions, c *clock) *Rotator {
+	t.Helper()
+	r := &Rotator{path: filepath.Join(t.TempDir(), "notes.log"), opts: opts, now: c.now}
+	if err := r.open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func write(t *testing.T, r *Rotator, s string) {
+	t.Helper()
+	if n, err := r.Write([]byte(s)); err != nil || n != len(s) {
+		t.Fatalf("Write(%q): %d, %v", s, n, err)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// backups дожидается фонового сжатия и возвращает содержимое старых файлов журнала
+// от старых к новым. Несжатых и временных файлов остаться не должно.
+func backups(t *testing.T, r *Rotator) []string {
+	t.Helper()
+	r.bg.Wait()
+	names, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	contents := []string{}
+	for _, name := range names {
+		if filepath.Ext(name) != ".gz" {
+			t.Errorf("unexpected file %s", filepath.Base(name))
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			t.Fatalf("%s: %v", filepath.Base(name), err)
+		}
+		raw, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(name), err)
+		}
+		contents = append(contents, string(raw))
+	}
+	return contents
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRotateSize(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newRotator(t, RotateOptions{MaxSize: 10}, c)
+
+	// Запись больше MaxSize в пустой файл не порождает пустой архив.
+	write(t, r, "0123456789ab\n")
+	c.advance(time.Second)
+	write(t, r, "one\n")
+	c.advance(time.Second)
+	write(t, r, "two\n")
+	// 4+4+4 > 10: третья запись начинает новый файл.
+	c.advance(time.Second)
+	write(t, r, "six\n")
+
+	if got, want := backups(t, r), []string{"0123456789ab\n", "one\ntwo\n"}; !equal(got, want) {
+		t.Errorf("backups %q, want %q", got, want)
+	}
+	if got := readFile(t, r.path); got != "six\n" {
+		t.Errorf("current file %q, want %q", got, "six\n")
+	}
+}
+
+func TestRotateAge(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newRotator(t, RotateOptions{MaxAge: time.Hour}, c)
+
+	write(t, r, "a\n")
+	c.advance(30 * time.Minute)
+	write(t, r, "b\n")
+	c.advance(30 * time.Minute)
+	write(t, r, "c\n")
+	// Время отсчитывается от открытия нового файла, а не от последней записи.
+	c.advance(59 * time.Minute)
+	write(t, r, "d\n")
+
+	if got, want := backups(t, r), []string{"a\nb\n"}; !equal(got, want) {
+		t.Errorf("backups %q, want %q", got, want)
+	}
+	if got := readFile(t, r.path); got != "c\nd\n" {
+		t.Errorf("current file %q, want %q", got, "c\nd\n")
+	}
+}
+
+func TestRotateMaxBackups(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newRotator(t, RotateOptions{MaxBackups: 2}, c)
+
+	for _, s := range []string{"1\n", "2\n", "3\n", "4\n", "5\n"} {
+		write(t, r, s)
+		c.advance(time.Second)
+		if err := r.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(t, r, "6\n")
+
+	if got, want := backups(t, r), []string{"4\n", "5\n"}; !equal(got, want) {
+		t.Errorf("backups %q, want %q", got, want)
+	}
+	if got := readFile(t, r.path); got != "6\n" {
+		t.Errorf("current file %q, want %q", got, "6\n")
+	}
+}
+
+// TestReopen проверяет, что после перемещения файла внешней программой Reopen начинает
+// новый файл, а перемещенный больше не меняется.
+func TestReopen(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newRotator(t, RotateOptions{MaxSize: 100}, c)
+
+	write(t, r, "old\n")
+	moved := r.path + ".moved"
+	if err := os.Rename(r.path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, r, "new\n")
+
+	if got := readFile(t, moved); got != "old\n" {
+		t.Errorf("moved file %q, want %q", got, "old\n")
+	}
+	if got := readFile(t, r.path); got != "new\n" {
+		t.Errorf("current file %q, want %q", got, "new\n")
+	}
+	// Размер считается заново: после Reopen до MaxSize еще далеко.
+	if r.size != 4 {
+		t.Errorf("size %d after Reopen, want 4", r.size)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: error %v, want os.ErrClosed", err)
+	}
+	if err := r.Reopen(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Reopen after Close: error %v, want os.ErrClosed", err)
+	}
+	if err := r.Rotate(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Rotate after Close: error %v, want os.ErrClosed", err)
+	}
+}
+
+// TestRotateRemoved проверяет, что удаленный снаружи файл просто начинается заново.
+func TestRotateRemoved(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newRotator(t, RotateOptions{}, c)
+
+	write(t, r, "lost\n")
+	if err := os.Remove(r.path); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, r, "next\n")
+
+	if got := backups(t, r); len(got) != 0 {
+		t.Errorf("backups %q, want none", got)
+	}
+	if got := readFile(t, r.path); got != "next\n" {
+		t.Errorf("current file %q, want %q", got, "next\n")
+	}
+}
@@ -0,0 +1,52 @@
+// Package mergepatch реализует JSON Merge Patch (RFC 7396).
+package mergepatch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContentType - тип содержимого тела запроса с JSON Merge Patch.
+const ContentType = "application/merge-patch+json"
+
+// Apply применяет patch к JSON-документу doc и возвращает результат.
+//
+// Если patch - объект, его поля рекурсивно сливаются с doc: поле со значением null
+// удаляется из документа, остальные поля заменяются или добавляются.
+// Если patch - не объект, он целиком заменяет документ.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("mergepatch: decode patch: %w", err)
+	}
+
+	var d interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &d); err != nil {
+			return nil, fmt.Errorf("mergepatch: decode document: %w", err)
+		}
+	}
+
+	return json.Marshal(merge(d, p))
+}
+
+// merge реализует алгоритм MergePatch(Target, Patch) из раздела 2 RFC 7396.
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = merge(t[name], value)
+	}
+	return t
+}
@@ -0,0 +1,83 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// normalize приводит JSON к виду, который выдает Apply: без пробелов, ключи по алфавиту.
+func normalize(t *testing.T, s string) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc, patch string
+		want       string
+	}{
+		// Примеры из приложения A RFC 7396.
+		{"replace", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"delete", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"delete one of two", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"value replaces array", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"value becomes array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"nested merge", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"arrays are not merged", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"array document", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"array patch replaces object", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"null replaces document", `{"a":"foo"}`, `null`, `null`},
+		{"string replaces document", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"null in document kept", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{"object patch over array", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"deep null", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+
+		// Поля заметки.
+		{"note fields", `{"id":1,"name":"Ivan","last_name":"Petrov","note":"text"}`,
+			`{"note":"new","last_name":null,"tags":{"x":1}}`,
+			`{"id":1,"name":"Ivan","note":"new","tags":{"x":1}}`},
+		{"empty patch", `{"id":1,"name":"Ivan"}`, `{}`, `{"id":1,"name":"Ivan"}`},
+		{"delete missing", `{"id":1}`, `{"name":null}`, `{"id":1}`},
+		{"empty document", ``, `{"a":1,"b":null}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply(%s, %s): %v", tt.doc, tt.patch, err)
+			}
+			if want := normalize(t, tt.want); string(got) != want {
+				t.Errorf("Apply(%s, %s) = %s, want %s", tt.doc, tt.patch, got, want)
+			}
+		})
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc, patch string
+	}{
+		{"truncated patch", `{"a":1}`, `{"a":`},
+		{"empty patch", `{"a":1}`, ``},
+		{"patch with trailing data", `{"a":1}`, `{"a":2} x`},
+		{"invalid document", `{"a":`, `{"a":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Apply([]byte(tt.doc), []byte(tt.patch)); err == nil {
+				t.Errorf("Apply(%s, %s) = %s, want error", tt.doc, tt.patch, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+// Package metrics собирает метрики и выводит их в текстовом формате Prometheus
+// (text exposition format 0.0.4) без зависимости от клиентской библиотеки Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType - тип содержимого ответа с метриками.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets - границы корзин гистограммы по умолчанию, в секундах.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelSep разделяет значения меток в ключе ряда; в тексте меток он не встречается.
+const labelSep = "\xff"
+
+// collector - метрика с одним или несколькими рядами.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry хранит метрики и выводит их в порядке имен.
+// Безопасен для одновременного использования.
+type Registry struct {
+	mtx        sync.Mutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (r *Registry) register(c collector) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, exists := r.collectors[c.name()]; exists {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteTo выводит все метрики в w в текстовом формате Prometheus.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.Lock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mtx.Unlock()
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// desc - имя, описание и имена меток метрики.
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) header(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, typ)
+}
+
+// key возвращает ключ ряда по значениям меток.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s: got %d label values, want %d", d.metricName, len(values), len(d.labels)))
+	}
+	return strings.Join(values, labelSep)
+}
+
+// labelPairs возвращает метки ряда в виде name="value",... и дополнительную пару extra, если она задана.
+func (d *desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, labelSep) {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec - счетчики с одинаковым именем и разными значениями меток.
+type CounterVec struct {
+	desc
+	mtx    sync.RWMutex
+	series map[string]*Counter
+}
+
+// Counter - монотонно растущий счетчик.
+type Counter struct {
+	bits atomic.Uint64 // float64
+}
+
+// Add увеличивает счетчик на v; v не может быть отрицательным.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	for {
+		old := c.bits.Load()
+		if c.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Inc увеличивает счетчик на 1.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// NewCounterVec регистрирует счетчик name с метками labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{desc: desc{name, help, labels}, series: make(map[string]*Counter)}
+	r.register(v)
+	return v
+}
+
+// With возвращает счетчик ряда со значениями меток values, создавая его при первом обращении.
+func (v *CounterVec) With(values ...string) *Counter {
+	key := v.key(values)
+	v.mtx.RLock()
+	c, ok := v.series[key]
+	v.mtx.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if c, ok = v.series[key]; !ok {
+		c = &Counter{}
+		v.series[key] = c
+	}
+	return c
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.header(w, "counter")
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	for _, key := range sortedKeys(v.series) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(key), formatFloat(v.series[key].value()))
+	}
+}
+
+// HistogramVec - гистограммы с одинаковым именем и границами корзин и разными значениями меток.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mtx     sync.RWMutex
+	series  map[string]*Histogram
+}
+
+// Histogram - распределение наблюдаемых значений по корзинам.
+type Histogram struct {
+	buckets []float64
+	mtx     sync.Mutex
+	counts  []uint64 // counts[i] - наблюдений не больше buckets[i]; не накопительно
+	sum     float64
+	count   uint64
+}
+
+// NewHistogramVec регистрирует гистограмму name с границами корзин buckets
+// (по возрастанию; nil - DefBuckets) и метками labels.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: " + name + ": buckets must be sorted")
+	}
+	v := &HistogramVec{desc: desc{name, help, labels}, buckets: buckets, series: make(map[string]*Histogram)}
+	r.register(v)
+	return v
+}
+
+// With возвращает гистограмму ряда со значениями меток values, создавая ее при первом обращении.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := v.key(values)
+	v.mtx.RLock()
+	h, ok := v.series[key]
+	v.mtx.RUnlock()
+	if ok {
+		return h
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if h, ok = v.series[key]; !ok {
+		h = &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = h
+	}
+	return h
+}
+
+// Observe добавляет наблюдение x.
+func (h *Histogram) Observe(x float64) {
+	i := sort.SearchFloat64s(h.buckets, x)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += x
+	h.count++
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.header(w, "histogram")
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	for _, key := range sortedKeys(v.series) {
+		h := v.series[key]
+		h.mtx.Lock()
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(key, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(key, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, v.labelPairs(key), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, v.labelPairs(key), h.count)
+		h.mtx.Unlock()
+	}
+}
+
+// Func - метрика, значения которой вычисляются при каждом выводе: например, размер хранилища.
+type Func struct {
+	desc
+	typ     string
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc регистрирует показатель name с метками labels. При выводе вызывается collect,
+// который передает emit значение каждого ряда и значения его меток.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) {
+	r.register(&Func{desc: desc{name, help, labels}, typ: "gauge", collect: collect})
+}
+
+// NewCounterFunc как NewGaugeFunc, но для значений, которые только растут (например, суммарное время ожидания).
+func (r *Registry) NewCounterFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) {
+	r.register(&Func{desc: desc{name, help, labels}, typ: "counter", collect: collect})
+}
+
+func (f *Func) write(w *bufio.Writer) {
+	series := make(map[string]float64)
+	f.collect(func(value float64, labelValues ...string) {
+		series[f.key(labelValues)] = value
+	})
+	f.header(w, f.typ)
+	for _, key := range sortedKeys(series) {
+		fmt.Fprintf(w, "%s%s %s\n", f.metricName, f.labelPairs(key), formatFloat(series[key]))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// countWriter считает записанные байты для WriteTo.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
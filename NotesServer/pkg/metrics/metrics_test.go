@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// write возвращает вывод реестра r.
+func write(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo returned %d bytes, wrote %d", n, b.Len())
+	}
+	return b.String()
+}
+
+func TestWriteTo(t *testing.T) {
+	tests := []struct {
+		name string
+		fill func(r *Registry)
+		want string
+	}{
+		{
+			name: "counter",
+			fill: func(r *Registry) {
+				c := r.NewCounterVec("requests_total", "Number of requests.", "method", "code")
+				c.With("POST", "201").Inc()
+				c.With("GET", "200").Add(2.5)
+				c.With("GET", "200").Inc()
+			},
+			want: `# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{method="GET",code="200"} 3.5
+requests_total{method="POST",code="201"} 1
+`,
+		},
+		{
+			name: "counter without labels",
+			fill: func(r *Registry) {
+				r.NewCounterVec("events_total", "Events.").With().Inc()
+			},
+			want: `# HELP events_total Events.
+# TYPE events_total counter
+events_total 1
+`,
+		},
+		{
+			name: "histogram",
+			fill: func(r *Registry) {
+				h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "handler")
+				h.With("/a").Observe(0.05)
+				h.With("/a").Observe(0.1) // граница входит в корзину
+				h.With("/a").Observe(0.5)
+				h.With("/a").Observe(3)
+			},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{handler="/a",le="0.1"} 2
+latency_seconds_bucket{handler="/a",le="1"} 3
+latency_seconds_bucket{handler="/a",le="+Inf"} 4
+latency_seconds_sum{handler="/a"} 3.65
+latency_seconds_count{handler="/a"} 4
+`,
+		},
+		{
+			name: "gauge and counter funcs",
+			fill: func(r *Registry) {
+				r.NewGaugeFunc("items", "Items.", nil, func(emit func(float64, ...string)) {
+					emit(42)
+				})
+				r.NewCounterFunc("wait_seconds_total", "Wait.", []string{"mode"}, func(emit func(float64, ...string)) {
+					emit(1.5, "write")
+					emit(0, "read")
+				})
+			},
+			want: `# HELP items Items.
+# TYPE items gauge
+items 42
+# HELP wait_seconds_total Wait.
+# TYPE wait_seconds_total counter
+wait_seconds_total{mode="read"} 0
+wait_seconds_total{mode="write"} 1.5
+`,
+		},
+		{
+			name: "func without series",
+			fill: func(r *Registry) {
+				r.NewGaugeFunc("empty", "Empty.", []string{"x"}, func(func(float64, ...string)) {})
+			},
+			want: `# HELP empty Empty.
+# TYPE empty gauge
+`,
+		},
+		{
+			name: "escaping",
+			fill: func(r *Registry) {
+				c := r.NewCounterVec("escaped_total", "Back\\slash \"quotes\"\nnew line.", "v")
+				c.With("a\"b\\c\nd").Inc()
+			},
+			want: `# HELP escaped_total Back\\slash "quotes"\nnew line.
+# TYPE escaped_total counter
+escaped_total{v="a\"b\\c\nd"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.fill(r)
+			if got := write(t, r); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{0.005, "0.005"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.v); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+// TestPanics проверяет ошибки использования: они обнаруживаются сразу, а не в выводе.
+func TestPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate metric", func(r *Registry) {
+			r.NewCounterVec("x_total", "X.")
+			r.NewGaugeFunc("x_total", "X.", nil, func(func(float64, ...string)) {})
+		}},
+		{"label count", func(r *Registry) {
+			r.NewCounterVec("x_total", "X.", "a", "b").With("a")
+		}},
+		{"negative add", func(r *Registry) {
+			r.NewCounterVec("x_total", "X.").With().Add(-1)
+		}},
+		{"unsorted buckets", func(r *Registry) {
+			r.NewHistogramVec("x_seconds", "X.", []float64{1, 0.5})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
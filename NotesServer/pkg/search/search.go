@@ -0,0 +1,179 @@
+// Package search реализует полнотекстовый поиск по инвертированному индексу.
+//
+// Текст разбивается на слова (последовательности букв и цифр любого алфавита),
+// слова приводятся к нижнему регистру, а "ё" заменяется на "е", поэтому поиск
+// одинаково работает с русским и латинским текстом. Документ находится, если
+// в нем есть все слова запроса; результаты упорядочены по TF-IDF.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Field - поле документа с весом: совпадение в поле с большим весом поднимает документ выше.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Hit - найденный документ и его релевантность.
+type Hit struct {
+	ID    int64
+	Score float64
+}
+
+// Index - инвертированный индекс: для каждого слова хранит документы,
+// в которых оно встречается, и взвешенную частоту слова в документе.
+// Безопасен для одновременного использования.
+type Index struct {
+	mtx   sync.RWMutex
+	terms map[string]map[int64]float64
+	docs  map[int64][]string // слова документа, чтобы удалять его из terms
+}
+
+func NewIndex() *Index {
+	return &Index{
+		terms: make(map[string]map[int64]float64),
+		docs:  make(map[int64][]string),
+	}
+}
+
+// Len возвращает количество документов в индексе.
+func (idx *Index) Len() int {
+	idx.mtx.RLock()
+	defer idx.mtx.RUnlock()
+	return len(idx.docs)
+}
+
+// Set индексирует документ id, заменяя его прежнее содержимое.
+func (idx *Index) Set(id int64, fields ...Field) {
+	freqs := make(map[string]float64)
+	for _, f := range fields {
+		for _, term := range Tokenize(f.Text) {
+			freqs[term] += f.Weight
+		}
+	}
+
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	idx.remove(id)
+	if len(freqs) == 0 {
+		return
+	}
+	terms := make([]string, 0, len(freqs))
+	for term, freq := range freqs {
+		postings, ok := idx.terms[term]
+		if !ok {
+			postings = make(map[int64]float64)
+			idx.terms[term] = postings
+		}
+		postings[id] = freq
+		terms = append(terms, term)
+	}
+	idx.docs[id] = terms
+}
+
+// Remove удаляет документ id из индекса.
+func (idx *Index) Remove(id int64) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	idx.remove(id)
+}
+
+// Clear удаляет из индекса все документы.
+func (idx *Index) Clear() {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	idx.terms = make(map[string]map[int64]float64)
+	idx.docs = make(map[int64][]string)
+}
+
+// Search возвращает документы, содержащие все слова запроса, по убыванию релевантности.
+// При равной релевантности документы упорядочены по id. limit <= 0 - без ограничения.
+func (idx *Index) Search(query string, limit int) []Hit {
+	terms := unique(Tokenize(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mtx.RLock()
+	defer idx.mtx.RUnlock()
+
+	postings := make([]map[int64]float64, len(terms))
+	for i, term := range terms {
+		postings[i] = idx.terms[term]
+		if len(postings[i]) == 0 {
+			return nil
+		}
+	}
+	// Кандидаты берутся из самого короткого списка, остальные только проверяются.
+	sort.Slice(postings, func(i, j int) bool { return len(postings[i]) < len(postings[j]) })
+
+	n := float64(len(idx.docs))
+	var hits []Hit
+next:
+	for id := range postings[0] {
+		score := 0.0
+		for _, p := range postings {
+			freq, ok := p[id]
+			if !ok {
+				continue next
+			}
+			score += freq * math.Log(1+n/float64(len(p)))
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// remove удаляет документ id; вызывается под блокировкой.
+func (idx *Index) remove(id int64) {
+	for _, term := range idx.docs[id] {
+		postings := idx.terms[term]
+		delete(postings, id)
+		if len(postings) == 0 {
+			delete(idx.terms, term)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Tokenize разбивает текст на слова: последовательности букв и цифр.
+// Слова приводятся к нижнему регистру, "ё" заменяется на "е".
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = strings.ReplaceAll(strings.ToLower(w), "ё", "е")
+	}
+	return words
+}
+
+// unique возвращает слова без повторов в исходном порядке.
+func unique(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	out := words[:0]
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"  ,.! ", []string{}},
+		{"Ёжик в тумане, 2024!", []string{"ежик", "в", "тумане", "2024"}},
+		{"ЁЛКА ёлка Елка", []string{"елка", "елка", "елка"}},
+		{"Hello-World_42", []string{"hello", "world", "42"}},
+		{"Buy MILK и хлеб", []string{"buy", "milk", "и", "хлеб"}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// ids возвращает id найденных документов в порядке результатов.
+func ids(hits []Hit) []int64 {
+	out := []int64{}
+	for _, h := range hits {
+		out = append(out, h.ID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	idx := NewIndex()
+	idx.Set(1, Field{Text: "молоко и хлеб", Weight: 1})
+	idx.Set(2, Field{Text: "молоко, молоко", Weight: 1})
+	idx.Set(3, Field{Text: "Молоко", Weight: 2})
+	idx.Set(4, Field{Text: "редкий редкий частый", Weight: 1})
+	idx.Set(5, Field{Text: "редкий частый частый", Weight: 1})
+	idx.Set(6, Field{Text: "частый", Weight: 1})
+	idx.Set(7, Field{Text: "частый", Weight: 1})
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []int64
+	}{
+		{"частота слова и вес поля, равные по id", "молоко", 0, []int64{2, 3, 1}},
+		{"все слова запроса", "хлеб молоко", 0, []int64{1}},
+		{"ё и регистр", "МОЛОКО", 0, []int64{2, 3, 1}},
+		{"редкое слово весит больше", "редкий частый", 0, []int64{4, 5}},
+		{"limit", "молоко", 2, []int64{2, 3}},
+		{"нет слова", "кефир", 0, []int64{}},
+		{"нет всех слов", "молоко кефир", 0, []int64{}},
+		{"пустой запрос", " ,", 0, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(idx.Search(tt.query, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+			}
+		})
+	}
+
+	// Релевантность - сумма по словам запроса: частота * log(1 + N/df).
+	hits := idx.Search("редкий частый", 0)
+	want := 2*math.Log(1+7.0/2) + math.Log(1+7.0/4)
+	if math.Abs(hits[0].Score-want) > 1e-9 {
+		t.Errorf("score of %d = %v, want %v", hits[0].ID, hits[0].Score, want)
+	}
+}
+
+// TestIndexSync проверяет, что изменение и удаление документа обновляют индекс.
+func TestIndexSync(t *testing.T) {
+	idx := NewIndex()
+	idx.Set(1, Field{Text: "купить молоко", Weight: 1})
+	idx.Set(2, Field{Text: "купить хлеб", Weight: 1})
+
+	// Повторный Set заменяет содержимое документа, старые слова больше не находят его.
+	idx.Set(1, Field{Text: "позвонить маме", Weight: 1})
+	steps := []struct {
+		query string
+		want  []int64
+	}{
+		{"молоко", []int64{}},
+		{"купить", []int64{2}},
+		{"маме", []int64{1}},
+	}
+	for _, s := range steps {
+		if got := ids(idx.Search(s.query, 0)); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("after Set: Search(%q) = %v, want %v", s.query, got, s.want)
+		}
+	}
+	if idx.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", idx.Len())
+	}
+
+	idx.Remove(2)
+	idx.Remove(3) // отсутствующий документ
+	if got := idx.Search("купить", 0); len(got) != 0 {
+		t.Errorf("after Remove: Search(купить) = %v", got)
+	}
+	if _, ok := idx.terms["хлеб"]; ok {
+		t.Error("after Remove: term хлеб still indexed")
+	}
+	if idx.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", idx.Len())
+	}
+
+	// Документ без слов в индекс не попадает.
+	idx.Set(1, Field{Text: "...", Weight: 1})
+	if idx.Len() != 0 || len(idx.terms) != 0 {
+		t.Errorf("after empty Set: %d docs, %d terms", idx.Len(), len(idx.terms))
+	}
+
+	idx.Set(4, Field{Text: "новая заметка", Weight: 1})
+	idx.Clear()
+	if idx.Len() != 0 || idx.Search("заметка", 0) != nil {
+		t.Error("after Clear: index not empty")
+	}
+}
@@ -0,0 +1,188 @@
+// Package validate проверяет поля структур по тегам validate и строго разбирает JSON.
+//
+// Правила перечисляются в теге через запятую:
+//
+//	required - строка не пустая и не состоит из одних пробелов, число не равно нулю
+//	min=N    - строка не короче N символов, число не меньше N
+//	max=N    - строка не длиннее N символов, число не больше N
+//
+// Поле в ошибках называется по тегу json, а если его нет - по имени поля Go.
+// Поддерживаются поля-строки и целые числа.
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Коды нарушенных правил в FieldError.Code.
+const (
+	CodeRequired = "required"
+	CodeMin      = "min"
+	CodeMax      = "max"
+	CodeUnknown  = "unknown" // поле JSON, которого нет в структуре
+)
+
+// FieldError - нарушение правила одним полем.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors - все нарушения, найденные при проверке. Реализует error;
+// получить список из обернутой ошибки можно через errors.As.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// rule - правило проверки поля; param - N для min и max.
+type rule struct {
+	code  string
+	param int64
+}
+
+// field - проверяемое поле структуры.
+type field struct {
+	index []int
+	name  string
+	rules []rule
+}
+
+// fieldsCache хранит разобранные теги для каждого типа структуры: reflect.Type -> []field.
+var fieldsCache sync.Map
+
+// Struct проверяет поля структуры v (или указателя на нее) и возвращает Errors
+// со всеми нарушениями или nil. Неверный тег - ошибка программы, поэтому вызывает панику.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+
+	var errs Errors
+	for _, f := range fieldsOf(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+		for _, r := range f.rules {
+			if msg, ok := check(fv, r); !ok {
+				errs = append(errs, FieldError{Field: f.name, Code: r.code, Message: msg})
+				// После пустого значения остальные правила поля ничего не добавят.
+				if r.code == CodeRequired {
+					break
+				}
+			}
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// fieldsOf возвращает проверяемые поля типа t.
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok || tag == "" {
+			continue
+		}
+		f := field{index: sf.Index, name: jsonName(sf)}
+		for _, part := range strings.Split(tag, ",") {
+			f.rules = append(f.rules, parseRule(t, sf, part))
+		}
+		fields = append(fields, f)
+	}
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+func parseRule(t reflect.Type, sf reflect.StructField, part string) rule {
+	code, param, hasParam := strings.Cut(strings.TrimSpace(part), "=")
+	switch code {
+	case CodeRequired:
+		if !hasParam {
+			return rule{code: code}
+		}
+	case CodeMin, CodeMax:
+		if n, err := strconv.ParseInt(param, 10, 64); err == nil && hasParam {
+			return rule{code: code, param: n}
+		}
+	}
+	panic(fmt.Sprintf("validate: %s.%s: invalid rule %q", t.Name(), sf.Name, part))
+}
+
+// jsonName возвращает имя поля в JSON.
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// check проверяет значение v по правилу r и возвращает сообщение о нарушении.
+func check(v reflect.Value, r rule) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		switch r.code {
+		case CodeRequired:
+			return "must not be empty or blank", strings.TrimSpace(s) != ""
+		case CodeMin:
+			return fmt.Sprintf("must be at least %d characters long", r.param), int64(utf8.RuneCountInString(s)) >= r.param
+		case CodeMax:
+			return fmt.Sprintf("must be at most %d characters long", r.param), int64(utf8.RuneCountInString(s)) <= r.param
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		switch r.code {
+		case CodeRequired:
+			return "is required", n != 0
+		case CodeMin:
+			return fmt.Sprintf("must be at least %d", r.param), n >= r.param
+		case CodeMax:
+			return fmt.Sprintf("must be at most %d", r.param), n <= r.param
+		}
+	}
+	panic(fmt.Sprintf("validate: rule %q is not supported for %s", r.code, v.Kind()))
+}
+
+// DecodeJSON разбирает JSON-объект data в v, как json.Unmarshal, но поля, которых нет
+// в v, не пропускаются, а возвращаются как Errors с кодом CodeUnknown.
+// Синтаксические ошибки и ошибки типов возвращаются как есть.
+func DecodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("validate: unexpected data after the JSON object")
+	}
+	if err == nil {
+		return nil
+	}
+	// encoding/json не экспортирует тип этой ошибки, только ее текст.
+	if name, ok := strings.CutPrefix(err.Error(), `json: unknown field "`); ok {
+		name = strings.TrimSuffix(name, `"`)
+		return Errors{{Field: name, Code: CodeUnknown, Message: "unknown field"}}
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type note struct {
+	ID       int64  `json:"id" validate:"min=0"`
+	Name     string `json:"name" validate:"required,min=2,max=5"`
+	LastName string `json:"last_name,omitempty" validate:"max=3"`
+	Count    int    `validate:"required,max=10"`
+	Comment  string // без тега не проверяется
+}
+
+// codes возвращает пары "поле:код" из ошибки Struct или DecodeJSON.
+func codes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error %v (%T) is not Errors", err, err)
+	}
+	out := make([]string, len(errs))
+	for i, fe := range errs {
+		out[i] = fe.Field + ":" + fe.Code
+	}
+	return out
+}
+
+func TestStruct(t *testing.T) {
+	valid := note{Name: "Ivan", LastName: "Li", Count: 1}
+	tests := []struct {
+		name   string
+		modify func(n *note)
+		want   []string
+	}{
+		{"valid", func(n *note) {}, nil},
+		{"empty", func(n *note) { n.Name = "" }, []string{"name:required"}},
+		// Пробельная строка пуста, а остальные правила поля после required не проверяются.
+		{"blank", func(n *note) { n.Name = " \t\n" }, []string{"name:required"}},
+		{"too short", func(n *note) { n.Name = "I" }, []string{"name:min"}},
+		{"min boundary", func(n *note) { n.Name = "Iv" }, nil},
+		{"max boundary", func(n *note) { n.Name = "Ivana" }, nil},
+		{"too long", func(n *note) { n.Name = "Ivanna" }, []string{"name:max"}},
+		// Длина считается в символах, а не в байтах.
+		{"cyrillic max", func(n *note) { n.Name = "Иваны" }, nil},
+		{"cyrillic too long", func(n *note) { n.Name = "Иванна" }, []string{"name:max"}},
+		{"cyrillic min", func(n *note) { n.Name = "Я" }, []string{"name:min"}},
+		{"whitespace counted", func(n *note) { n.Name = " Iv " }, nil},
+		{"optional empty", func(n *note) { n.LastName = "" }, nil},
+		{"field name without json tag", func(n *note) { n.Count = 0 }, []string{"Count:required"}},
+		{"int max", func(n *note) { n.Count = 11 }, []string{"Count:max"}},
+		{"int min", func(n *note) { n.ID = -1 }, []string{"id:min"}},
+		{"untagged", func(n *note) { n.Comment = strings.Repeat("x", 100) }, nil},
+		{"all fields", func(n *note) {
+			n.ID = -1
+			n.Name = ""
+			n.LastName = "Petrov"
+			n.Count = 0
+		}, []string{"id:min", "name:required", "last_name:max", "Count:required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := valid
+			tt.modify(&n)
+			// Struct принимает и структуру, и указатель на нее.
+			for _, v := range []any{n, &n} {
+				if got := codes(t, Struct(v)); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Struct(%T): %v, want %v", v, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStructMessage(t *testing.T) {
+	err := Struct(note{Name: "Ivanna", LastName: "Li", Count: 1})
+	want := "validation failed: name: must be at most 5 characters long"
+	if err == nil || err.Error() != want {
+		t.Errorf("Struct: error %v, want %q", err, want)
+	}
+
+	raw, _ := json.Marshal(err)
+	if string(raw) != `[{"field":"name","code":"max","message":"must be at most 5 characters long"}]` {
+		t.Errorf("json: %s", raw)
+	}
+}
+
+func TestStructPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"not a struct", "Ivan"},
+		{"unknown rule", struct {
+			S string `validate:"email"`
+		}{}},
+		{"min without param", struct {
+			S string `validate:"min"`
+		}{}},
+		{"bad param", struct {
+			S string `validate:"max=ten"`
+		}{}},
+		{"required with param", struct {
+			S string `validate:"required=1"`
+		}{}},
+		{"unsupported type", struct {
+			F float64 `validate:"required"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Struct(%#v) did not panic", tt.v)
+				}
+			}()
+			Struct(tt.v)
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    note
+		wantErr []string // нарушения Errors
+		typeErr bool     // ошибка типа поля
+		bad     bool     // прочая ошибка разбора
+	}{
+		{data: `{"id":1,"name":"Ivan","Count":2}`, want: note{ID: 1, Name: "Ivan", Count: 2}},
+		{data: `{"name":"Ivan","nmae":"x"}`, wantErr: []string{"nmae:unknown"}},
+		{data: `{"name":1}`, typeErr: true},
+		{data: `{"id":"1"}`, typeErr: true},
+		{data: `{"name":`, bad: true},
+		{data: `{"name":"Ivan"} {}`, bad: true},
+	}
+	for _, tt := range tests {
+		var got note
+		err := DecodeJSON([]byte(tt.data), &got)
+		var errs Errors
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case tt.wantErr != nil:
+			if c := codes(t, err); !reflect.DeepEqual(c, tt.wantErr) {
+				t.Errorf("%s: %v, want %v", tt.data, c, tt.wantErr)
+			}
+		case tt.typeErr:
+			if !errors.As(err, &typeErr) {
+				t.Errorf("%s: error %v (%T), want *json.UnmarshalTypeError", tt.data, err, err)
+			}
+		case tt.bad:
+			if err == nil || errors.As(err, &errs) || errors.As(err, &typeErr) {
+				t.Errorf("%s: error %v (%T), want a decode error", tt.data, err, err)
+			}
+		default:
+			if err != nil || got != tt.want {
+				t.Errorf("%s: %+v, %v, want %+v", tt.data, got, err, tt.want)
+			}
+		}
+	}
+}